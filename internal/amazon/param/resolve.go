@@ -0,0 +1,178 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package param resolves parameter values that reference ssm parameter store or secrets
+// manager e.g. ssm:/path/name or secret:arn#key
+package param
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/ssmiface"
+)
+
+const (
+	// PrefixSSM identifies values read from ssm parameter store e.g. ssm:/path/name
+	PrefixSSM = "ssm:"
+	// PrefixSecret identifies values read from secrets manager e.g. secret:arn#key.  The
+	// key is optional; when provided, the secret is parsed as json and the key extracted.
+	PrefixSecret = "secret:"
+)
+
+// IsReference returns true if the value refers to ssm parameter store or secrets manager
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, PrefixSSM) || strings.HasPrefix(value, PrefixSecret)
+}
+
+type resolved struct {
+	value     string
+	sensitive bool
+}
+
+// entry holds a reference being, or already, read.  ready is closed once item and err
+// are set.
+type entry struct {
+	ready chan struct{}
+	item  resolved
+	err   error
+}
+
+// Resolver reads referenced values.  Values are cached so each reference is read once,
+// even when resolved concurrently.  References that could not be read are read again.
+type Resolver struct {
+	ssm     ssmiface.ClientAPI
+	secrets secretsmanageriface.ClientAPI
+
+	mutex sync.Mutex
+	cache map[string]*entry
+}
+
+// New returns a resolver that reads values using the credentials provided
+func New(config aws.Config) *Resolver {
+	return &Resolver{
+		ssm:     ssm.New(config),
+		secrets: secretsmanager.New(config),
+		cache:   map[string]*entry{},
+	}
+}
+
+// Resolve returns the value referred to by value.  Values that are not references are
+// returned unchanged.  sensitive is true when the value was read from secrets manager or
+// from an ssm SecureString and should not be logged.
+func (r *Resolver) Resolve(ctx context.Context, value string) (v string, sensitive bool, err error) {
+	if !IsReference(value) {
+		return value, false, nil
+	}
+
+	// the lock guards only the cache; references are read without it so slow reads do not
+	// hold up others
+	r.mutex.Lock()
+	e, ok := r.cache[value]
+	if !ok {
+		e = &entry{ready: make(chan struct{})}
+		r.cache[value] = e
+	}
+	r.mutex.Unlock()
+
+	if ok {
+		select {
+		case <-e.ready:
+		case <-ctx.Done():
+			return "", false, ctx.Err()
+		}
+		if e.err != nil {
+			return "", false, e.err
+		}
+		return e.item.value, e.item.sensitive, nil
+	}
+
+	switch {
+	case strings.HasPrefix(value, PrefixSSM):
+		e.item, e.err = r.getParameter(ctx, strings.TrimPrefix(value, PrefixSSM))
+	default:
+		e.item, e.err = r.getSecret(ctx, strings.TrimPrefix(value, PrefixSecret))
+	}
+	if e.err != nil {
+		r.mutex.Lock()
+		delete(r.cache, value)
+		r.mutex.Unlock()
+	}
+	close(e.ready)
+
+	if e.err != nil {
+		return "", false, e.err
+	}
+	return e.item.value, e.item.sensitive, nil
+}
+
+func (r *Resolver) getParameter(ctx context.Context, name string) (resolved, error) {
+	input := ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	}
+	resp, err := r.ssm.GetParameterRequest(&input).Send(ctx)
+	if err != nil {
+		return resolved{}, fmt.Errorf("unable to read ssm parameter, %v: %w", name, err)
+	}
+	if resp.Parameter == nil {
+		return resolved{}, fmt.Errorf("unable to read ssm parameter, %v: parameter not found", name)
+	}
+
+	return resolved{
+		value:     aws.StringValue(resp.Parameter.Value),
+		sensitive: resp.Parameter.Type == ssm.ParameterTypeSecureString,
+	}, nil
+}
+
+func (r *Resolver) getSecret(ctx context.Context, ref string) (resolved, error) {
+	id, key := ref, ""
+	if i := strings.LastIndex(ref, "#"); i >= 0 {
+		id, key = ref[:i], ref[i+1:]
+	}
+
+	input := secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(id),
+	}
+	resp, err := r.secrets.GetSecretValueRequest(&input).Send(ctx)
+	if err != nil {
+		return resolved{}, fmt.Errorf("unable to read secret, %v: %w", id, err)
+	}
+
+	secret := aws.StringValue(resp.SecretString)
+	if resp.SecretString == nil {
+		secret = string(resp.SecretBinary)
+	}
+	if key == "" {
+		return resolved{value: secret, sensitive: true}, nil
+	}
+
+	var content map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &content); err != nil {
+		return resolved{}, fmt.Errorf("unable to read key, %v, from secret, %v: secret is not a json object", key, id)
+	}
+	v, ok := content[key]
+	if !ok {
+		return resolved{}, fmt.Errorf("unable to read key, %v, from secret, %v: key not found", key, id)
+	}
+
+	return resolved{value: fmt.Sprint(v), sensitive: true}, nil
+}
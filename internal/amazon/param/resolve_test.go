@@ -0,0 +1,264 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package param
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/defaults"
+)
+
+// standIn emulates the subset of the ssm and secrets manager apis used by Resolver
+type standIn struct {
+	parameters map[string]string
+	secure     map[string]bool
+	secrets    map[string]string
+	// blocked holds, by name, parameters whose reads wait until the channel is closed
+	blocked map[string]chan struct{}
+
+	mutex sync.Mutex
+	calls int
+}
+
+// Calls returns the number of requests served
+func (s *standIn) Calls() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.calls
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mutex.Lock()
+	s.calls++
+	s.mutex.Unlock()
+
+	var input struct {
+		Name     string
+		SecretId string
+	}
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ch, ok := s.blocked[input.Name]; ok {
+		<-ch
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	switch req.Header.Get("X-Amz-Target") {
+	case "AmazonSSM.GetParameter":
+		value, ok := s.parameters[input.Name]
+		if !ok {
+			writeError(w, "ParameterNotFound")
+			return
+		}
+		parameterType := "String"
+		if s.secure[input.Name] {
+			parameterType = "SecureString"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"Parameter": map[string]interface{}{
+				"Name":  input.Name,
+				"Type":  parameterType,
+				"Value": value,
+			},
+		})
+
+	case "secretsmanager.GetSecretValue":
+		value, ok := s.secrets[input.SecretId]
+		if !ok {
+			writeError(w, "ResourceNotFoundException")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"ARN":          input.SecretId,
+			"SecretString": value,
+		})
+
+	default:
+		http.Error(w, "unsupported target", http.StatusBadRequest)
+	}
+}
+
+func writeError(w http.ResponseWriter, code string) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"__type":  code,
+		"message": "not found",
+	})
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	const arn = "arn:aws:secretsmanager:us-west-2:123456789012:secret:db-AbCdEf"
+
+	handler := &standIn{
+		parameters: map[string]string{
+			"/app/domain":   "example.com",
+			"/app/password": "hunter2",
+		},
+		secure: map[string]bool{
+			"/app/password": true,
+		},
+		secrets: map[string]string{
+			arn: `{"username":"admin","port":5432}`,
+		},
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	config := defaults.Config()
+	config.Region = "us-west-2"
+	config.Credentials = aws.NewStaticCredentialsProvider("AKID", "SECRET", "")
+	config.EndpointResolver = aws.ResolveWithEndpointURL(server.URL)
+
+	testCases := map[string]struct {
+		Value     string
+		Want      string
+		Sensitive bool
+		HasError  bool
+	}{
+		"literal": {
+			Value: "t3.micro",
+			Want:  "t3.micro",
+		},
+		"ssm": {
+			Value: "ssm:/app/domain",
+			Want:  "example.com",
+		},
+		"ssm secure string": {
+			Value:     "ssm:/app/password",
+			Want:      "hunter2",
+			Sensitive: true,
+		},
+		"ssm not found": {
+			Value:    "ssm:/app/missing",
+			HasError: true,
+		},
+		"secret": {
+			Value:     "secret:" + arn,
+			Want:      `{"username":"admin","port":5432}`,
+			Sensitive: true,
+		},
+		"secret key": {
+			Value:     "secret:" + arn + "#port",
+			Want:      "5432",
+			Sensitive: true,
+		},
+		"secret key not found": {
+			Value:    "secret:" + arn + "#password",
+			HasError: true,
+		},
+		"secret not found": {
+			Value:    "secret:arn:aws:secretsmanager:us-west-2:123456789012:secret:missing",
+			HasError: true,
+		},
+	}
+
+	ctx := context.Background()
+	resolver := New(config)
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			got, sensitive, err := resolver.Resolve(ctx, tc.Value)
+			if tc.HasError {
+				if err == nil {
+					t.Fatalf("got nil; want err")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			if got != tc.Want {
+				t.Fatalf("got %v; want %v", got, tc.Want)
+			}
+			if sensitive != tc.Sensitive {
+				t.Fatalf("got %v; want %v", sensitive, tc.Sensitive)
+			}
+		})
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		calls := handler.Calls()
+		if _, _, err := resolver.Resolve(ctx, "ssm:/app/domain"); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := handler.Calls(), calls; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
+
+func TestResolver_Resolve_concurrent(t *testing.T) {
+	unblock := make(chan struct{})
+	handler := &standIn{
+		parameters: map[string]string{
+			"/app/domain": "example.com",
+			"/app/slow":   "slow",
+		},
+		blocked: map[string]chan struct{}{
+			"/app/slow": unblock,
+		},
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	config := defaults.Config()
+	config.Region = "us-west-2"
+	config.Credentials = aws.NewStaticCredentialsProvider("AKID", "SECRET", "")
+	config.EndpointResolver = aws.ResolveWithEndpointURL(server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resolver := New(config)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := resolver.Resolve(ctx, "ssm:/app/slow")
+			errs <- err
+		}()
+	}
+
+	// a slow read must not hold up reads of other references
+	if got, _, err := resolver.Resolve(ctx, "ssm:/app/domain"); err != nil || got != "example.com" {
+		t.Fatalf("got %v, %v; want example.com, nil", got, err)
+	}
+
+	close(unblock)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	}
+
+	// concurrent resolves of the same reference read it once
+	if got, want := handler.Calls(), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
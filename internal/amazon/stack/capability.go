@@ -0,0 +1,108 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// namedIAMProperties holds, by resource type, the property that assigns a custom name to
+// an iam resource
+var namedIAMProperties = map[string]string{
+	"AWS::IAM::Group":           "GroupName",
+	"AWS::IAM::InstanceProfile": "InstanceProfileName",
+	"AWS::IAM::ManagedPolicy":   "ManagedPolicyName",
+	"AWS::IAM::Role":            "RoleName",
+	"AWS::IAM::User":            "UserName",
+}
+
+// stackCapabilities returns the capabilities the stack template requires along with any
+// capabilities declared by the stack config
+func stackCapabilities(stack Stack) ([]cloudformation.Capability, error) {
+	capabilities, err := requiredCapabilities(stack.TemplateBody)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range stack.Capabilities {
+		capabilities = appendCapability(capabilities, c)
+	}
+	return capabilities, nil
+}
+
+// requiredCapabilities returns the capabilities cloudformation requires to be
+// acknowledged for the template.  CAPABILITY_IAM is required for iam resources and for
+// serverless functions, which create roles implicitly; CAPABILITY_NAMED_IAM for iam
+// resources with custom names; and CAPABILITY_AUTO_EXPAND for templates that use
+// transforms or macros.  Nested stacks and serverless applications may contain anything
+// so every capability is requested for them.
+func requiredCapabilities(body string) ([]cloudformation.Capability, error) {
+	content, err := parseTemplate(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var iam, namedIAM, autoExpand bool
+
+	if _, ok := content["Transform"]; ok {
+		autoExpand = true
+	}
+	walk(content, func(m map[string]interface{}) {
+		if _, ok := m["Fn::Transform"]; ok {
+			autoExpand = true
+		}
+	})
+
+	resources, _ := content["Resources"].(map[string]interface{})
+	for _, v := range resources {
+		t := resourceType(v)
+		switch {
+		case t == "AWS::CloudFormation::Stack" || t == "AWS::Serverless::Application":
+			iam, namedIAM, autoExpand = true, true, true
+		case t == "AWS::Serverless::Function" || t == "AWS::Serverless::StateMachine":
+			iam = true
+		case strings.HasPrefix(t, "AWS::IAM::"):
+			iam = true
+			resource, _ := v.(map[string]interface{})
+			properties, _ := resource["Properties"].(map[string]interface{})
+			if name, ok := namedIAMProperties[t]; ok && properties[name] != nil {
+				namedIAM = true
+			}
+		}
+	}
+
+	var capabilities []cloudformation.Capability
+	if iam {
+		capabilities = append(capabilities, cloudformation.CapabilityCapabilityIam)
+	}
+	if namedIAM {
+		capabilities = append(capabilities, cloudformation.CapabilityCapabilityNamedIam)
+	}
+	if autoExpand {
+		capabilities = append(capabilities, cloudformation.CapabilityCapabilityAutoExpand)
+	}
+	return capabilities, nil
+}
+
+// appendCapability appends the capability unless it is already present
+func appendCapability(capabilities []cloudformation.Capability, c cloudformation.Capability) []cloudformation.Capability {
+	for _, item := range capabilities {
+		if item == c {
+			return capabilities
+		}
+	}
+	return append(capabilities, c)
+}
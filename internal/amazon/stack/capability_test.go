@@ -0,0 +1,112 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+func Test_requiredCapabilities(t *testing.T) {
+	const (
+		iam        = cloudformation.CapabilityCapabilityIam
+		namedIAM   = cloudformation.CapabilityCapabilityNamedIam
+		autoExpand = cloudformation.CapabilityCapabilityAutoExpand
+	)
+
+	testCases := map[string]struct {
+		Body string
+		Want []cloudformation.Capability
+	}{
+		"none": {
+			Body: `
+Resources:
+  Topic:
+    Type: AWS::SNS::Topic
+`,
+		},
+		"iam": {
+			Body: `
+Resources:
+  Role:
+    Type: AWS::IAM::Role
+    Properties:
+      AssumeRolePolicyDocument: {}
+`,
+			Want: []cloudformation.Capability{iam},
+		},
+		"named iam": {
+			Body: `
+Resources:
+  Role:
+    Type: AWS::IAM::Role
+    Properties:
+      RoleName: !Sub '${AWS::StackName}-role'
+      AssumeRolePolicyDocument: {}
+`,
+			Want: []cloudformation.Capability{iam, namedIAM},
+		},
+		"serverless": {
+			Body: `
+Transform: AWS::Serverless-2016-10-31
+Resources:
+  Function:
+    Type: AWS::Serverless::Function
+    Properties:
+      CodeUri: s3://bucket/key.zip
+      Handler: index.handler
+      Runtime: nodejs12.x
+`,
+			Want: []cloudformation.Capability{iam, autoExpand},
+		},
+		"macro": {
+			Body: `
+Resources:
+  Topic:
+    Type: AWS::SNS::Topic
+    Properties:
+      Fn::Transform:
+        Name: AWS::Include
+        Parameters:
+          Location: s3://bucket/topic.yaml
+`,
+			Want: []cloudformation.Capability{autoExpand},
+		},
+		"nested stack": {
+			Body: `
+Resources:
+  Child:
+    Type: AWS::CloudFormation::Stack
+    Properties:
+      TemplateURL: https://bucket.s3.amazonaws.com/child.template
+`,
+			Want: []cloudformation.Capability{iam, namedIAM, autoExpand},
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			got, err := requiredCapabilities(tc.Body)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			if !reflect.DeepEqual(got, tc.Want) {
+				t.Fatalf("got %v; want %v", got, tc.Want)
+			}
+		})
+	}
+}
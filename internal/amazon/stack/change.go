@@ -15,6 +15,8 @@
 package stack
 
 import (
+	"fmt"
+
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 )
 
@@ -25,7 +27,9 @@ var (
 	}
 )
 
-func CalculateChanges(got []cloudformation.StackSummary, want []Stack) []Change {
+// CalculateChanges returns the changes required to move from the stacks deployed to the
+// stacks wanted.  Changes are ordered by stack dependency; see Stack.DependsOn.
+func CalculateChanges(got []cloudformation.StackSummary, want []Stack) ([]Change, error) {
 	got = exclude(got, deletedStatus...)
 
 	var changes []Change
@@ -36,6 +40,7 @@ loop:
 				changes = append(changes, Change{
 					Operation: Update,
 					Stack:     w,
+					Status:    g.StackStatus,
 				})
 				continue loop
 			}
@@ -56,9 +61,16 @@ del:
 		changes = append(changes, Change{
 			Operation: Delete,
 			Stack:     Stack{Name: *g.StackName},
+			Status:    g.StackStatus,
 		})
 	}
-	return changes
+
+	changes, err := sortChanges(changes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to calculate changes: %w", err)
+	}
+
+	return changes, nil
 }
 
 func exclude(item []cloudformation.StackSummary, statuses ...cloudformation.StackStatus) []cloudformation.StackSummary {
@@ -54,7 +54,10 @@ func TestMakeChangeSet(t *testing.T) {
 
 	for label, tc := range testCases {
 		t.Run(label, func(t *testing.T) {
-			changes := CalculateChanges(makeStackSummaries(tc.summaries), makeStacks(tc.stacks))
+			changes, err := CalculateChanges(makeStackSummaries(tc.summaries), makeStacks(tc.stacks))
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
 			if got, want := tc.wantInserts, stackNames(filter(changes, Insert)); !reflect.DeepEqual(got, want) {
 				t.Fatalf("got %v; want %v", got, want)
 			}
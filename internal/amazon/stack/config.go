@@ -0,0 +1,206 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/sanathkr/go-yaml"
+)
+
+// configSuffixes holds the suffixes of the sidecar files that accompany templates e.g.
+// table.config.yaml configures table.template
+var configSuffixes = []string{".config.yaml", ".config.yml", ".config.json"}
+
+// Config holds the stack specific settings read from the optional sidecar file that
+// accompanies a template
+type Config struct {
+	// Capabilities to acknowledge in addition to those the template is found to require
+	Capabilities []string `yaml:"capabilities"`
+	// Environments, if set, restricts the stack to the environments listed
+	Environments []string `yaml:"environments"`
+	// ExcludeEnvironments lists environments the stack should not be deployed to
+	ExcludeEnvironments []string `yaml:"excludeEnvironments"`
+	// Import maps the logical ids of resources to the physical identifiers of existing
+	// resources the stack should take over e.g. Table: orders or Table: {TableName: orders}
+	Import map[string]interface{} `yaml:"import"`
+	// Namespace replaces the namespace of the parameters the stack outputs are published as
+	Namespace string `yaml:"namespace"`
+	// NotificationARNs holds the sns topics that receive stack events
+	NotificationARNs []string `yaml:"notificationARNs"`
+	// Outputs maps output keys to additional parameter names they are published as
+	Outputs map[string]string `yaml:"outputs"`
+	// Parameters holds stack specific parameters.  These replace global parameters of the same name.
+	Parameters map[string]string `yaml:"parameters"`
+	// StackPolicy holds the stack policy either as a json string or as a yaml document
+	StackPolicy interface{} `yaml:"stackPolicy"`
+	// Tags holds stack specific tags
+	Tags map[string]string `yaml:"tags"`
+	// TerminationProtection, if set, enables or disables termination protection
+	TerminationProtection *bool `yaml:"terminationProtection"`
+	// Timeout bounds how long to wait for a create, update, or delete e.g. 30m
+	Timeout string `yaml:"timeout"`
+}
+
+// isConfig returns true if the filename is a sidecar config file
+func isConfig(filename string) bool {
+	for _, suffix := range configSuffixes {
+		if strings.HasSuffix(filename, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadConfig reads the sidecar config for the template filename provided.  A zero
+// Config is returned if no sidecar exists.
+func loadConfig(filename string) (Config, error) {
+	base := trimExt(filename)
+	for _, suffix := range configSuffixes {
+		path := base + suffix
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return Config{}, fmt.Errorf("unable to read stack config, %v: %w", path, err)
+		}
+
+		config, err := parseConfig(data)
+		if err != nil {
+			return Config{}, fmt.Errorf("unable to parse stack config, %v: %w", path, err)
+		}
+		return config, nil
+	}
+	return Config{}, nil
+}
+
+func parseConfig(data []byte) (Config, error) {
+	parseMutex.Lock()
+	defer parseMutex.Unlock()
+
+	var config Config
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// apply copies the settings from the config onto the stack
+func (c Config) apply(stack *Stack) error {
+	for _, s := range c.Capabilities {
+		if !containsString(knownCapabilities, s) {
+			return fmt.Errorf("unknown capability, %v", s)
+		}
+		stack.Capabilities = append(stack.Capabilities, cloudformation.Capability(s))
+	}
+
+	if c.Timeout != "" {
+		timeout, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout, %v: %w", c.Timeout, err)
+		}
+		stack.Timeout = timeout
+	}
+
+	imports, err := makeResourcesToImport(stack.TemplateBody, c.Import)
+	if err != nil {
+		return fmt.Errorf("invalid import: %w", err)
+	}
+	stack.ResourcesToImport = imports
+
+	if c.StackPolicy != nil {
+		policy, err := formatPolicy(c.StackPolicy)
+		if err != nil {
+			return fmt.Errorf("invalid stack policy: %w", err)
+		}
+		stack.StackPolicy = policy
+	}
+
+	var keys []string
+	for key := range c.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var tags []cloudformation.Tag
+	for _, key := range keys {
+		tags = append(tags, cloudformation.Tag{Key: aws.String(key), Value: aws.String(c.Tags[key])})
+	}
+	stack.Tags = mergeTags(stack.Tags, tags)
+
+	stack.Environments = c.Environments
+	stack.ExcludeEnvironments = c.ExcludeEnvironments
+	if c.Namespace != "" {
+		stack.Namespace = c.Namespace
+	}
+
+	stack.NotificationARNs = c.NotificationARNs
+	stack.OutputParameters = c.Outputs
+	stack.Parameters = c.Parameters
+	stack.TerminationProtection = c.TerminationProtection
+
+	return nil
+}
+
+var knownCapabilities = []string{
+	string(cloudformation.CapabilityCapabilityIam),
+	string(cloudformation.CapabilityCapabilityNamedIam),
+	string(cloudformation.CapabilityCapabilityAutoExpand),
+}
+
+// formatPolicy returns the stack policy as a json document
+func formatPolicy(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		if !json.Valid([]byte(s)) {
+			return "", fmt.Errorf("stack policy is not valid json")
+		}
+		return s, nil
+	}
+
+	data, err := json.Marshal(jsonValue(v))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// jsonValue converts the generic yaml value into one that may be marshaled as json
+func jsonValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, item := range value {
+			m[fmt.Sprint(k)] = jsonValue(item)
+		}
+		return m
+	case []interface{}:
+		ss := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			ss = append(ss, jsonValue(item))
+		}
+		return ss
+	default:
+		return value
+	}
+}
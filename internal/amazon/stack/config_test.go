@@ -0,0 +1,123 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+func TestLoadFile_config(t *testing.T) {
+	s, err := LoadFile("testdata/config/table.template",
+		WithPrefix("prod"),
+		WithTags(makeTag("env", "prod"), makeTag("team", "platform")),
+	)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if got, want := s.Parameters, map[string]string{"ReadCapacity": "5"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := formatTags(s.Tags), "env=prod,team=data"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := s.Capabilities, []cloudformation.Capability{cloudformation.CapabilityCapabilityAutoExpand}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := aws.BoolValue(s.TerminationProtection), true; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := s.StackPolicy, `{"Statement":[{"Action":"Update:*","Effect":"Allow","Principal":"*","Resource":"*"}]}`; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := s.NotificationARNs, []string{"arn:aws:sns:us-west-2:123456789012:events"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := s.Timeout, 30*time.Minute; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, err := stackCapabilities(s); err != nil || !reflect.DeepEqual(got, s.Capabilities) {
+		t.Fatalf("got %v, %v; want %v", got, err, s.Capabilities)
+	}
+}
+
+func TestLoadAll_environments(t *testing.T) {
+	testCases := map[string]struct {
+		Env  string
+		Want []string
+	}{
+		"local": {
+			Env:  "local",
+			Want: nil,
+		},
+		"dev": {
+			Env:  "dev",
+			Want: []string{"table"},
+		},
+		"prod": {
+			Env:  "prod",
+			Want: []string{"network", "table"},
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			stacks, err := LoadAll("testdata/config", WithParameters(map[string]string{Env: tc.Env}))
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			if got := stackNames(stacks); !reflect.DeepEqual(got, tc.Want) {
+				t.Fatalf("got %v; want %v", got, tc.Want)
+			}
+		})
+	}
+}
+
+func Test_parseConfig(t *testing.T) {
+	t.Run("unknown field", func(t *testing.T) {
+		if _, err := parseConfig([]byte("parameter:\n  Foo: bar\n")); err == nil {
+			t.Fatalf("got nil; want err")
+		}
+	})
+
+	t.Run("unknown capability", func(t *testing.T) {
+		config, err := parseConfig([]byte("capabilities: [CAPABILITY_BOGUS]\n"))
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if err := config.apply(&Stack{}); err == nil {
+			t.Fatalf("got nil; want err")
+		}
+	})
+
+	t.Run("json stack policy", func(t *testing.T) {
+		config, err := parseConfig([]byte(`stackPolicy: '{"Statement": []}'`))
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		var s Stack
+		if err := config.apply(&s); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := s.StackPolicy, `{"Statement": []}`; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/fatih/color"
+)
+
+// Event describes a change in status of a stack or one of its resources
+type Event struct {
+	StackName    string    `json:"stackName"`
+	EventID      string    `json:"eventId"`
+	LogicalID    string    `json:"logicalId"`
+	PhysicalID   string    `json:"physicalId,omitempty"`
+	ResourceType string    `json:"resourceType"`
+	Status       string    `json:"status"`
+	StatusReason string    `json:"statusReason,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// EventHandler receives the events of the stacks being changed.  Handlers are invoked
+// concurrently when stacks are applied concurrently and must be safe for concurrent use.
+type EventHandler func(event Event)
+
+// makeEvent converts a cloudformation stack event into an Event
+func makeEvent(event cloudformation.StackEvent) Event {
+	return Event{
+		StackName:    aws.StringValue(event.StackName),
+		EventID:      aws.StringValue(event.EventId),
+		LogicalID:    aws.StringValue(event.LogicalResourceId),
+		PhysicalID:   aws.StringValue(event.PhysicalResourceId),
+		ResourceType: aws.StringValue(event.ResourceType),
+		Status:       string(event.ResourceStatus),
+		StatusReason: aws.StringValue(event.ResourceStatusReason),
+		Timestamp:    aws.TimeValue(event.Timestamp),
+	}
+}
+
+// Console writes stack events and plans to the console as lines colored by status.
+// Writes are serialized so the lines of stacks changed concurrently do not interleave.
+// When prefixed, lines are preceded by the stack name to distinguish those stacks.  A
+// Console is safe for concurrent use and may be shared by managers.
+type Console struct {
+	w        io.Writer
+	prefixed bool
+	mutex    sync.Mutex
+}
+
+// NewConsole returns a Console that writes to w
+func NewConsole(w io.Writer, prefixed bool) *Console {
+	return &Console{
+		w:        w,
+		prefixed: prefixed,
+	}
+}
+
+// prefix returns the text that precedes the lines of the stack
+func (c *Console) prefix(stackName string) string {
+	if !c.prefixed {
+		return ""
+	}
+	return "[" + stackName + "] "
+}
+
+// Event writes the event as a single line.  Event is an EventHandler.
+func (c *Console) Event(event Event) {
+	text := c.prefix(event.StackName) + fmt.Sprintf("%s %-25s %-35s %-35s %s\n",
+		event.Timestamp.In(time.Local).Format("2006/01/02 15:04:05"),
+		event.LogicalID,
+		event.ResourceType,
+		event.Status,
+		event.StatusReason,
+	)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	eventColor(event.Status).Fprint(c.w, text)
+}
+
+// ConsoleEvents returns an EventHandler that writes each event to w as a line colored by
+// status.  When prefixed, lines are preceded by the stack name to distinguish stacks
+// observed concurrently.
+func ConsoleEvents(w io.Writer, prefixed bool) EventHandler {
+	return NewConsole(w, prefixed).Event
+}
+
+// eventColor returns the color of console lines for events with the status provided
+func eventColor(status string) *color.Color {
+	switch {
+	case strings.Contains(status, "FAILED") || strings.Contains(status, "DELETE"):
+		return color.New(color.FgRed)
+	case strings.Contains(status, "UPDATE"):
+		return color.New(color.FgYellow)
+	case strings.Contains(status, "CREATE"):
+		return color.New(color.FgGreen)
+	default:
+		return color.New(color.FgBlue)
+	}
+}
+
+// JSONEvents returns an EventHandler that writes each event to w as a line of json,
+// suitable for ci logs and for files consumed by reports and notifications
+func JSONEvents(w io.Writer) EventHandler {
+	var (
+		mutex   sync.Mutex
+		encoder = json.NewEncoder(w)
+	)
+	return func(event Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if err := encoder.Encode(event); err != nil {
+			log.Printf("unable to write event for stack, %v: %v\n", event.StackName, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/fatih/color"
+)
+
+func Test_makeEvent(t *testing.T) {
+	timestamp := time.Date(2020, time.May, 1, 12, 0, 0, 0, time.UTC)
+	got := makeEvent(cloudformation.StackEvent{
+		EventId:              aws.String("abc"),
+		LogicalResourceId:    aws.String("Table"),
+		PhysicalResourceId:   aws.String("local-example-table"),
+		ResourceStatus:       cloudformation.ResourceStatusCreateFailed,
+		ResourceStatusReason: aws.String("already exists"),
+		ResourceType:         aws.String("AWS::DynamoDB::Table"),
+		StackName:            aws.String("local-example"),
+		Timestamp:            &timestamp,
+	})
+
+	want := Event{
+		StackName:    "local-example",
+		EventID:      "abc",
+		LogicalID:    "Table",
+		PhysicalID:   "local-example-table",
+		ResourceType: "AWS::DynamoDB::Table",
+		Status:       "CREATE_FAILED",
+		StatusReason: "already exists",
+		Timestamp:    timestamp,
+	}
+	if got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestConsoleEvents(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	event := Event{
+		StackName:    "local-example",
+		LogicalID:    "Table",
+		ResourceType: "AWS::DynamoDB::Table",
+		Status:       "CREATE_COMPLETE",
+		Timestamp:    time.Now(),
+	}
+
+	buf := bytes.NewBuffer(nil)
+	ConsoleEvents(buf, true)(event)
+	if got := buf.String(); !strings.HasPrefix(got, "[local-example] ") || !strings.Contains(got, "CREATE_COMPLETE") {
+		t.Fatalf("got %v; want prefixed event", got)
+	}
+
+	buf.Reset()
+	ConsoleEvents(buf, false)(event)
+	if got := buf.String(); strings.HasPrefix(got, "[") {
+		t.Fatalf("got %v; want event without prefix", got)
+	}
+}
+
+func TestJSONEvents(t *testing.T) {
+	var (
+		buf     = bytes.NewBuffer(nil)
+		handler = JSONEvents(buf)
+		wg      sync.WaitGroup
+		n       = 10
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler(Event{StackName: "local-example", Status: "UPDATE_COMPLETE"})
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if got, want := len(lines), n; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	for _, line := range lines {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := event.Status, "UPDATE_COMPLETE"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// maxFailures bounds the number of failed resources reported for each stack operation
+const maxFailures = 5
+
+// startResourceStatuses hold the stack statuses that mark the start of a stack operation
+var startResourceStatuses = []cloudformation.ResourceStatus{
+	cloudformation.ResourceStatusCreateInProgress,
+	cloudformation.ResourceStatusUpdateInProgress,
+	cloudformation.ResourceStatusDeleteInProgress,
+	cloudformation.ResourceStatusImportInProgress,
+}
+
+// isFailed returns true if the stack status indicates the latest operation failed
+func isFailed(status cloudformation.StackStatus) bool {
+	s := string(status)
+	return strings.HasSuffix(s, "_FAILED") || strings.HasSuffix(s, "ROLLBACK_COMPLETE")
+}
+
+// Failure describes a resource whose change failed
+type Failure struct {
+	StackName    string
+	LogicalID    string
+	ResourceType string
+	Status       string
+	Reason       string
+}
+
+func (f Failure) String() string {
+	return fmt.Sprintf("%v %v (%v) %v: %v", f.StackName, f.LogicalID, f.ResourceType, f.Status, f.Reason)
+}
+
+// FailureError is returned when a stack operation fails.  Failures holds the first
+// resources to fail, oldest first, including those within nested stacks; the first is
+// typically the root cause.
+type FailureError struct {
+	StackName string
+	Failures  []Failure
+	Err       error
+}
+
+func (e *FailureError) Error() string {
+	if len(e.Failures) == 0 {
+		return e.Err.Error()
+	}
+
+	var reasons []string
+	for _, f := range e.Failures {
+		reasons = append(reasons, f.String())
+	}
+	return e.Err.Error() + ": " + strings.Join(reasons, "; ")
+}
+
+func (e *FailureError) Unwrap() error {
+	return e.Err
+}
+
+// Failures returns the failed stack operations of this manager, in the order they failed
+func (m *Manager) Failures() []*FailureError {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([]*FailureError(nil), m.failures...)
+}
+
+// failureError describes the resources that caused the latest operation on the stack to
+// fail and records it for Failures.  err is returned as is if it is due to cancellation.
+func (m *Manager) failureError(ctx context.Context, stackName string, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+
+	failures, derr := m.describeFailures(ctx, stackName, maxFailures)
+	if derr != nil {
+		log.Printf("unable to describe failures of stack, %v: %v\n", stackName, derr)
+	}
+
+	fe := &FailureError{
+		StackName: stackName,
+		Failures:  failures,
+		Err:       err,
+	}
+
+	m.mutex.Lock()
+	m.failures = append(m.failures, fe)
+	m.mutex.Unlock()
+
+	return fe
+}
+
+// describeFailures returns up to limit failures from the latest operation on the stack.
+// Failures of nested stacks are followed by the failures within the nested stack.
+func (m *Manager) describeFailures(ctx context.Context, stackName string, limit int) ([]Failure, error) {
+	var events []cloudformation.StackEvent
+
+	input := cloudformation.DescribeStackEventsInput{
+		StackName: aws.String(stackName),
+	}
+	for {
+		resp, err := m.api.DescribeStackEventsRequest(&input).Send(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to describe events of stack, %v: %w", stackName, err)
+		}
+
+		var started bool
+		for _, event := range resp.StackEvents {
+			events = append(events, event)
+			if isStart(event) {
+				started = true
+				break
+			}
+		}
+
+		input.NextToken = resp.NextToken
+		if started || input.NextToken == nil {
+			break
+		}
+	}
+
+	var failures []Failure
+	for _, event := range findFailures(events) {
+		if len(failures) >= limit {
+			break
+		}
+		failures = append(failures, makeFailure(event))
+
+		// the event of the stack itself also has the stack type; only nested stacks are followed
+		if !isStackEvent(event) && aws.StringValue(event.ResourceType) == "AWS::CloudFormation::Stack" && aws.StringValue(event.PhysicalResourceId) != "" {
+			nested, err := m.describeFailures(ctx, aws.StringValue(event.PhysicalResourceId), limit-len(failures))
+			if err != nil {
+				return failures, err
+			}
+			failures = append(failures, nested...)
+		}
+	}
+
+	return failures, nil
+}
+
+// findFailures returns the failed events of the latest stack operation, oldest first.
+// events must be ordered newest first, as returned by DescribeStackEvents.  Resources
+// cancelled because another resource failed are omitted as is the failure of the stack
+// itself unless no resource failed.
+func findFailures(events []cloudformation.StackEvent) []cloudformation.StackEvent {
+	var resources, stacks []cloudformation.StackEvent
+	for _, event := range events {
+		if isStart(event) {
+			break
+		}
+		if !strings.HasSuffix(string(event.ResourceStatus), "FAILED") || isCancelled(event) {
+			continue
+		}
+		if isStackEvent(event) {
+			stacks = append([]cloudformation.StackEvent{event}, stacks...)
+			continue
+		}
+		resources = append([]cloudformation.StackEvent{event}, resources...)
+	}
+
+	if len(resources) == 0 {
+		return stacks
+	}
+	return resources
+}
+
+// isStackEvent returns true if the event describes the stack rather than one of its resources
+func isStackEvent(event cloudformation.StackEvent) bool {
+	return aws.StringValue(event.LogicalResourceId) == aws.StringValue(event.StackName)
+}
+
+// isStart returns true if the event marks the start of a stack operation
+func isStart(event cloudformation.StackEvent) bool {
+	return isStackEvent(event) && containsResourceStatus(event, startResourceStatuses...)
+}
+
+// isCancelled returns true if the resource failed only because another resource failed
+func isCancelled(event cloudformation.StackEvent) bool {
+	reason := aws.StringValue(event.ResourceStatusReason)
+	return strings.HasPrefix(reason, "Resource creation cancelled") ||
+		strings.HasPrefix(reason, "Resource update cancelled")
+}
+
+func makeFailure(event cloudformation.StackEvent) Failure {
+	return Failure{
+		StackName:    aws.StringValue(event.StackName),
+		LogicalID:    aws.StringValue(event.LogicalResourceId),
+		ResourceType: aws.StringValue(event.ResourceType),
+		Status:       string(event.ResourceStatus),
+		Reason:       aws.StringValue(event.ResourceStatusReason),
+	}
+}
+
+// SummarizeFailures renders the failed stack operations as a concise report, one line
+// per failed resource
+func SummarizeFailures(failures ...*FailureError) string {
+	buf := &strings.Builder{}
+	for _, fe := range failures {
+		fmt.Fprintf(buf, "%v\n", fe.StackName)
+		if len(fe.Failures) == 0 {
+			fmt.Fprintf(buf, "  %v\n", fe.Err)
+			continue
+		}
+		for _, f := range fe.Failures {
+			fmt.Fprintf(buf, "  %v\n", f)
+		}
+	}
+	return buf.String()
+}
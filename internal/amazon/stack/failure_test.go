@@ -0,0 +1,203 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// makeStackEvent returns an event of the stack itself, when logicalID is the stack name, as
+// cloudformation reports it, or of a queue within the stack
+func makeStackEvent(stackName, logicalID string, status cloudformation.ResourceStatus, reason string) cloudformation.StackEvent {
+	event := cloudformation.StackEvent{
+		LogicalResourceId:    aws.String(logicalID),
+		ResourceStatus:       status,
+		ResourceStatusReason: aws.String(reason),
+		ResourceType:         aws.String("AWS::SQS::Queue"),
+		StackName:            aws.String(stackName),
+	}
+	if logicalID == stackName {
+		event.ResourceType = aws.String("AWS::CloudFormation::Stack")
+		event.PhysicalResourceId = aws.String(stackName)
+	}
+	return event
+}
+
+// makeNestedStackEvent returns an event of the nested stack, nested, within the stack
+func makeNestedStackEvent(stackName, logicalID, nested string, status cloudformation.ResourceStatus, reason string) cloudformation.StackEvent {
+	event := makeStackEvent(stackName, logicalID, status, reason)
+	event.ResourceType = aws.String("AWS::CloudFormation::Stack")
+	event.PhysicalResourceId = aws.String(nested)
+	return event
+}
+
+func Test_findFailures(t *testing.T) {
+	testCases := map[string]struct {
+		Events []cloudformation.StackEvent
+		Want   []string
+	}{
+		"resource failures, oldest first": {
+			Events: []cloudformation.StackEvent{
+				makeStackEvent("app", "app", cloudformation.ResourceStatusUpdateFailed, "The following resource(s) failed to update: [Queue]."),
+				makeStackEvent("app", "Table", cloudformation.ResourceStatusUpdateFailed, "Resource update cancelled"),
+				makeStackEvent("app", "Queue", cloudformation.ResourceStatusUpdateFailed, "Queue already exists"),
+				makeStackEvent("app", "Topic", cloudformation.ResourceStatusCreateFailed, "Topic limit exceeded"),
+				makeStackEvent("app", "app", cloudformation.ResourceStatusUpdateInProgress, "User Initiated"),
+				makeStackEvent("app", "Old", cloudformation.ResourceStatusCreateFailed, "failure of an earlier operation"),
+			},
+			Want: []string{"Topic", "Queue"},
+		},
+		"stack failure only": {
+			Events: []cloudformation.StackEvent{
+				makeStackEvent("app", "app", cloudformation.ResourceStatusDeleteFailed, "Export in use"),
+				makeStackEvent("app", "app", cloudformation.ResourceStatusDeleteInProgress, "User Initiated"),
+			},
+			Want: []string{"app"},
+		},
+		"none": {
+			Events: []cloudformation.StackEvent{
+				makeStackEvent("app", "app", cloudformation.ResourceStatusCreateComplete, ""),
+				makeStackEvent("app", "app", cloudformation.ResourceStatusCreateInProgress, "User Initiated"),
+			},
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			var got []string
+			for _, event := range findFailures(tc.Events) {
+				got = append(got, aws.StringValue(event.LogicalResourceId))
+			}
+			if !reflect.DeepEqual(got, tc.Want) {
+				t.Fatalf("got %v; want %v", got, tc.Want)
+			}
+		})
+	}
+}
+
+func Test_isFailed(t *testing.T) {
+	testCases := map[cloudformation.StackStatus]bool{
+		cloudformation.StackStatusCreateComplete:         false,
+		cloudformation.StackStatusDeleteComplete:         false,
+		cloudformation.StackStatusUpdateComplete:         false,
+		cloudformation.StackStatusCreateFailed:           true,
+		cloudformation.StackStatusDeleteFailed:           true,
+		cloudformation.StackStatusRollbackComplete:       true,
+		cloudformation.StackStatusUpdateRollbackComplete: true,
+		cloudformation.StackStatusUpdateRollbackFailed:   true,
+	}
+
+	for status, want := range testCases {
+		t.Run(string(status), func(t *testing.T) {
+			if got := isFailed(status); got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFailureError(t *testing.T) {
+	cause := errors.New("waiter failed")
+	err := &FailureError{
+		StackName: "app",
+		Failures: []Failure{
+			{StackName: "app", LogicalID: "Queue", ResourceType: "AWS::SQS::Queue", Status: "CREATE_FAILED", Reason: "Queue already exists"},
+			{StackName: "app-Nested-ABC", LogicalID: "Topic", ResourceType: "AWS::SNS::Topic", Status: "CREATE_FAILED", Reason: "Topic limit exceeded"},
+		},
+		Err: cause,
+	}
+
+	if got, want := err.Error(), "waiter failed: app Queue (AWS::SQS::Queue) CREATE_FAILED: Queue already exists; app-Nested-ABC Topic (AWS::SNS::Topic) CREATE_FAILED: Topic limit exceeded"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if !errors.Is(err, cause) {
+		t.Fatalf("got false; want true")
+	}
+
+	want := "app\n  app Queue (AWS::SQS::Queue) CREATE_FAILED: Queue already exists\n  app-Nested-ABC Topic (AWS::SNS::Topic) CREATE_FAILED: Topic limit exceeded\n"
+	if got := SummarizeFailures(err); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestManager_describeFailures(t *testing.T) {
+	testCases := map[string]struct {
+		Events map[string][]cloudformation.StackEvent
+		Want   []string
+		Calls  int
+	}{
+		"stack failure only": {
+			Events: map[string][]cloudformation.StackEvent{
+				"app": {
+					makeStackEvent("app", "app", cloudformation.ResourceStatusDeleteFailed, "Export in use"),
+					makeStackEvent("app", "app", cloudformation.ResourceStatusDeleteInProgress, "User Initiated"),
+				},
+			},
+			Want:  []string{"app app"},
+			Calls: 1,
+		},
+		"nested stack": {
+			Events: map[string][]cloudformation.StackEvent{
+				"app": {
+					makeStackEvent("app", "app", cloudformation.ResourceStatusUpdateFailed, "The following resource(s) failed to update: [Nested]."),
+					makeNestedStackEvent("app", "Nested", "app-Nested-ABC", cloudformation.ResourceStatusUpdateFailed, "Embedded stack was not successfully updated"),
+					makeStackEvent("app", "app", cloudformation.ResourceStatusUpdateInProgress, "User Initiated"),
+				},
+				"app-Nested-ABC": {
+					makeStackEvent("app-Nested-ABC", "app-Nested-ABC", cloudformation.ResourceStatusUpdateFailed, "The following resource(s) failed to update: [Queue]."),
+					makeStackEvent("app-Nested-ABC", "Queue", cloudformation.ResourceStatusUpdateFailed, "Queue already exists"),
+					makeStackEvent("app-Nested-ABC", "app-Nested-ABC", cloudformation.ResourceStatusUpdateInProgress, "User Initiated"),
+				},
+			},
+			Want:  []string{"app Nested", "app-Nested-ABC Queue"},
+			Calls: 2,
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			api := &stubAPI{StackEvents: tc.Events}
+			failures, err := New(api).describeFailures(context.Background(), "app", maxFailures)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+
+			var got []string
+			for _, f := range failures {
+				got = append(got, f.StackName+" "+f.LogicalID)
+			}
+			if !reflect.DeepEqual(got, tc.Want) {
+				t.Fatalf("got %v; want %v", got, tc.Want)
+			}
+
+			var calls int
+			for _, call := range api.Calls() {
+				if strings.HasPrefix(call, "DescribeStackEvents ") {
+					calls++
+				}
+			}
+			if got, want := calls, tc.Calls; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrCycle is returned when stacks depend on one another through their exports and imports
+var ErrCycle = errors.New("stack dependency cycle")
+
+// link assigns DependsOn for each stack that imports an export of another stack or
+// declares a parameter provided by the outputs of another stack
+func link(stacks []Stack) []Stack {
+	exporters := map[string]string{}
+	for _, s := range stacks {
+		for _, export := range s.Exports {
+			exporters[export] = s.Name
+		}
+	}
+
+	providers := providedParameters(stacks)
+
+	for i, s := range stacks {
+		var dependsOn []string
+		for _, name := range s.DependsOn {
+			dependsOn = appendUnique(dependsOn, name)
+		}
+		for _, imp := range s.Imports {
+			if exporter, ok := exporters[imp]; ok && exporter != s.Name {
+				dependsOn = appendUnique(dependsOn, exporter)
+			}
+		}
+		for _, param := range s.ParameterKeys {
+			if provider, ok := providers[param]; ok && provider != s.Name {
+				dependsOn = appendUnique(dependsOn, provider)
+			}
+		}
+		stacks[i].DependsOn = dependsOn
+	}
+
+	return stacks
+}
+
+// sortStacks orders stacks such that each stack follows the stacks it depends on
+func sortStacks(stacks []Stack) ([]Stack, error) {
+	var nodes []node
+	for _, s := range stacks {
+		nodes = append(nodes, node{name: s.Name, dependsOn: s.DependsOn})
+	}
+
+	indexes, err := order(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	var sorted []Stack
+	for _, i := range indexes {
+		sorted = append(sorted, stacks[i])
+	}
+	return sorted, nil
+}
+
+// sortChanges orders changes such that each change follows the changes of the stacks it
+// depends on.  Apply processes deletes in reverse so dependents are deleted first.
+func sortChanges(changes []Change) ([]Change, error) {
+	indexes, err := order(dependencyNodes(changes))
+	if err != nil {
+		return nil, err
+	}
+
+	var sorted []Change
+	for _, i := range indexes {
+		sorted = append(sorted, changes[i])
+	}
+	return sorted, nil
+}
+
+type node struct {
+	name      string
+	dependsOn []string
+}
+
+// dependencyNodes returns a node for each change that depends on the stacks the change
+// depends on
+func dependencyNodes(changes []Change) []node {
+	var nodes []node
+	for _, c := range changes {
+		nodes = append(nodes, node{name: c.Stack.Name, dependsOn: c.Stack.DependsOn})
+	}
+	return nodes
+}
+
+// dependentNodes returns a node for each change that depends on the stacks that depend
+// on it; used to delete stacks only after their dependents have been deleted
+func dependentNodes(changes []Change) []node {
+	dependents := map[string][]string{}
+	for _, c := range changes {
+		for _, name := range c.Stack.DependsOn {
+			dependents[name] = append(dependents[name], c.Stack.Name)
+		}
+	}
+
+	var nodes []node
+	for _, c := range changes {
+		nodes = append(nodes, node{name: c.Stack.Name, dependsOn: dependents[c.Stack.Name]})
+	}
+	return nodes
+}
+
+func reverse(changes []Change) []Change {
+	var reversed []Change
+	for i := len(changes) - 1; i >= 0; i-- {
+		reversed = append(reversed, changes[i])
+	}
+	return reversed
+}
+
+// order performs a depth first topological sort of the nodes and returns their indexes.
+// Nodes without dependencies between them retain their original relative order.
+// Dependencies on names not contained in nodes are ignored.
+func order(nodes []node) ([]int, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	var (
+		index  = map[string]int{}
+		state  = make([]int, len(nodes))
+		path   []string
+		sorted []int
+	)
+	for i, n := range nodes {
+		index[n.name] = i
+	}
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			start := 0
+			for j, name := range path {
+				if name == nodes[i].name {
+					start = j
+				}
+			}
+			cycle := append(path[start:len(path):len(path)], nodes[i].name)
+			return fmt.Errorf("%w: %v", ErrCycle, strings.Join(cycle, " -> "))
+		}
+
+		state[i] = visiting
+		path = append(path, nodes[i].name)
+		for _, name := range nodes[i].dependsOn {
+			if j, ok := index[name]; ok {
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		sorted = append(sorted, i)
+
+		return nil
+	}
+
+	for i := range nodes {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
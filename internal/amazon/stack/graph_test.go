@@ -0,0 +1,157 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLoadAll_imports(t *testing.T) {
+	opts := []Option{
+		WithPrefix("local-imports"),
+		WithParameters(map[string]string{Env: "local"}),
+	}
+
+	stacks, err := LoadAll("testdata/imports", opts...)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	want := []string{"local-imports-network", "local-imports-table", "local-imports-api"}
+	if got := stackNames(stacks); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	api := stacks[2]
+	wantImports := []string{"local-imports-network-VpcId", "local-imports-table-TableName"}
+	if got := api.Imports; !reflect.DeepEqual(got, wantImports) {
+		t.Fatalf("got %v; want %v", got, wantImports)
+	}
+	wantDependsOn := []string{"local-imports-network", "local-imports-table"}
+	if got := api.DependsOn; !reflect.DeepEqual(got, wantDependsOn) {
+		t.Fatalf("got %v; want %v", got, wantDependsOn)
+	}
+}
+
+func TestCalculateChanges_order(t *testing.T) {
+	stacks := []Stack{
+		{Name: "api", DependsOn: []string{"table"}},
+		{Name: "table", DependsOn: []string{"network"}},
+		{Name: "network"},
+	}
+	changes, err := CalculateChanges(makeStackSummaries([]string{"table", "old-api", "old-table"}), stacks)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	want := []string{"insert network", "update table", "insert api", "delete old-api", "delete old-table"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func Test_sortStacks(t *testing.T) {
+	t.Run("cycle", func(t *testing.T) {
+		stacks := []Stack{
+			{Name: "a", DependsOn: []string{"b"}},
+			{Name: "b", DependsOn: []string{"c"}},
+			{Name: "c", DependsOn: []string{"a"}},
+		}
+		_, err := sortStacks(stacks)
+		if !errors.Is(err, ErrCycle) {
+			t.Fatalf("got %v; want %v", err, ErrCycle)
+		}
+		if got, want := err.Error(), "stack dependency cycle: a -> b -> c -> a"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("stable", func(t *testing.T) {
+		stacks := []Stack{
+			{Name: "a"},
+			{Name: "b", DependsOn: []string{"d"}},
+			{Name: "c"},
+			{Name: "d", DependsOn: []string{"external"}},
+		}
+		sorted, err := sortStacks(stacks)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := stackNames(sorted), []string{"a", "d", "b", "c"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
+
+func Test_resolveString(t *testing.T) {
+	vars := map[string]string{
+		"AWS::StackName": "local-example-table",
+		"Env":            "local",
+	}
+
+	testCases := map[string]struct {
+		Input interface{}
+		Want  string
+		OK    bool
+	}{
+		"literal": {
+			Input: "abc",
+			Want:  "abc",
+			OK:    true,
+		},
+		"ref": {
+			Input: map[string]interface{}{"Ref": "Env"},
+			Want:  "local",
+			OK:    true,
+		},
+		"sub": {
+			Input: map[string]interface{}{"Fn::Sub": "${AWS::StackName}-Arn"},
+			Want:  "local-example-table-Arn",
+			OK:    true,
+		},
+		"sub with vars": {
+			Input: map[string]interface{}{"Fn::Sub": []interface{}{"${Name}-Arn", map[string]interface{}{"Name": "abc"}}},
+			Want:  "abc-Arn",
+			OK:    true,
+		},
+		"join": {
+			Input: map[string]interface{}{"Fn::Join": []interface{}{"-", []interface{}{map[string]interface{}{"Ref": "Env"}, "abc"}}},
+			Want:  "local-abc",
+			OK:    true,
+		},
+		"unknown": {
+			Input: map[string]interface{}{"Fn::Sub": "${Unknown}-Arn"},
+			OK:    false,
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			got, ok := resolveString(tc.Input, vars)
+			if ok != tc.OK {
+				t.Fatalf("got %v; want %v", ok, tc.OK)
+			}
+			if ok && got != tc.Want {
+				t.Fatalf("got %v; want %v", got, tc.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// ErrDeleteRefused is returned when Apply would delete stacks it has not been permitted to
+var ErrDeleteRefused = errors.New("stack delete refused")
+
+// statefulResourceTypes holds the resource types whose deletion destroys data
+var statefulResourceTypes = []string{
+	"AWS::DynamoDB::Table",
+	"AWS::EFS::FileSystem",
+	"AWS::RDS::DBCluster",
+	"AWS::RDS::DBInstance",
+	"AWS::S3::Bucket",
+}
+
+// guardDeletes removes retained stacks from the changes and verifies the remaining
+// deletes are permitted.  The resources of each stack to be deleted are reported prior
+// to any checks so the operator can see what would be destroyed.
+func (m *Manager) guardDeletes(ctx context.Context, changes []Change) ([]Change, error) {
+	var (
+		kept    []Change
+		deletes []string
+		risks   []string
+	)
+	for _, change := range changes {
+		if change.Operation != Delete {
+			kept = append(kept, change)
+			continue
+		}
+
+		name := change.Stack.Name
+		if m.isRetained(name) {
+			log.Printf("retaining stack, %v.  stack is no longer backed by a template, but will not be deleted\n", name)
+			continue
+		}
+
+		plan, err := m.previewDelete(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		reasons, err := m.deleteRisks(ctx, plan)
+		if err != nil {
+			return nil, err
+		}
+
+		deletes = append(deletes, name)
+		risks = append(risks, reasons...)
+		kept = append(kept, change)
+	}
+
+	if len(deletes) == 0 {
+		return kept, nil
+	}
+	if !m.options.AllowDelete {
+		return nil, fmt.Errorf("%w: stacks no longer backed by a template, %v, may only be deleted when deletes are allowed",
+			ErrDeleteRefused,
+			strings.Join(deletes, ", "),
+		)
+	}
+	if len(risks) > 0 && !m.options.ForceDelete {
+		return nil, fmt.Errorf("%w: deletes must be forced; %v",
+			ErrDeleteRefused,
+			strings.Join(risks, "; "),
+		)
+	}
+
+	return kept, nil
+}
+
+// deleteRisks returns the reasons, if any, deleting the stack described by the plan
+// requires force
+func (m *Manager) deleteRisks(ctx context.Context, plan Plan) ([]string, error) {
+	var reasons []string
+
+	input := cloudformation.DescribeStacksInput{
+		StackName: aws.String(plan.StackName),
+	}
+	resp, err := m.api.DescribeStacksRequest(&input).Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe stack, %v: %w", plan.StackName, err)
+	}
+	for _, s := range resp.Stacks {
+		if aws.BoolValue(s.EnableTerminationProtection) {
+			reasons = append(reasons, fmt.Sprintf("stack, %v, has termination protection enabled", plan.StackName))
+		}
+	}
+
+	for _, c := range plan.Changes {
+		if containsString(statefulResourceTypes, c.ResourceType) {
+			reasons = append(reasons, fmt.Sprintf("stack, %v, contains stateful resource, %v (%v)", plan.StackName, c.LogicalID, c.ResourceType))
+		}
+	}
+
+	return reasons, nil
+}
+
+// isRetained returns true if the stack should never be deleted.  Retained stacks may be
+// identified by full stack name or by template name.
+func (m *Manager) isRetained(stackName string) bool {
+	for _, name := range m.options.Retain {
+		if name == stackName || m.options.Prefix+m.options.FormatName(name) == stackName {
+			return true
+		}
+	}
+	return false
+}
+
+// disableTerminationProtection turns off termination protection so a forced delete may proceed
+func (m *Manager) disableTerminationProtection(ctx context.Context, stackName string) error {
+	input := cloudformation.UpdateTerminationProtectionInput{
+		EnableTerminationProtection: aws.Bool(false),
+		StackName:                   aws.String(stackName),
+	}
+	if _, err := m.api.UpdateTerminationProtectionRequest(&input).Send(ctx); err != nil {
+		return fmt.Errorf("unable to disable termination protection for stack, %v: %w", stackName, err)
+	}
+	return nil
+}
+
+func containsString(ss []string, want string) bool {
+	for _, s := range ss {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestManager_guardDeletes(t *testing.T) {
+	manager := New(nil,
+		WithPrefix("local-example"),
+		WithNameFormatter(func(s string) string { return "-" + s }),
+		WithRetain("table", "local-example--queue"),
+	)
+
+	changes := []Change{
+		{Operation: Insert, Stack: Stack{Name: "local-example--api"}},
+		{Operation: Delete, Stack: Stack{Name: "local-example--table"}},
+		{Operation: Delete, Stack: Stack{Name: "local-example--queue"}},
+	}
+
+	got, err := manager.guardDeletes(context.Background(), changes)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if want := changes[0:1]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	if manager.isRetained("local-example--api") {
+		t.Fatalf("got true; want false")
+	}
+}
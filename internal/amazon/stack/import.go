@@ -0,0 +1,242 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// identifierProperties holds the identifier property of common resource types so the
+// import mapping may give the physical id alone e.g. Table: orders
+var identifierProperties = map[string]string{
+	"AWS::DynamoDB::Table":        "TableName",
+	"AWS::ECR::Repository":        "RepositoryName",
+	"AWS::EFS::FileSystem":        "FileSystemId",
+	"AWS::KMS::Key":               "KeyId",
+	"AWS::Logs::LogGroup":         "LogGroupName",
+	"AWS::RDS::DBCluster":         "DBClusterIdentifier",
+	"AWS::RDS::DBInstance":        "DBInstanceIdentifier",
+	"AWS::S3::Bucket":             "BucketName",
+	"AWS::SNS::Topic":             "TopicArn",
+	"AWS::SQS::Queue":             "QueueUrl",
+	"AWS::SecretsManager::Secret": "Id",
+}
+
+// makeResourcesToImport converts the import mapping of the stack config, logical id to
+// physical identifier, into the resources to import.  Each resource must be declared
+// by the template with DeletionPolicy: Retain as cloudformation requires.
+func makeResourcesToImport(body string, mapping map[string]interface{}) ([]cloudformation.ResourceToImport, error) {
+	if len(mapping) == 0 {
+		return nil, nil
+	}
+
+	content, err := parseTemplate(body)
+	if err != nil {
+		return nil, err
+	}
+	resources, _ := content["Resources"].(map[string]interface{})
+
+	var ids []string
+	for id := range mapping {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var imports []cloudformation.ResourceToImport
+	for _, id := range ids {
+		resource, ok := resources[id].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("resource to import, %v, is not declared by the template", id)
+		}
+		if policy := resource["DeletionPolicy"]; policy != "Retain" {
+			return nil, fmt.Errorf("resource to import, %v, must set DeletionPolicy: Retain", id)
+		}
+
+		typ := resourceType(resource)
+		identifier := map[string]string{}
+		switch v := jsonValue(mapping[id]).(type) {
+		case string:
+			property, ok := identifierProperties[typ]
+			if !ok {
+				return nil, fmt.Errorf("resource to import, %v: identifier property of %v is unknown; specify the identifier as property: value", id, typ)
+			}
+			identifier[property] = v
+		case map[string]interface{}:
+			for property, value := range v {
+				identifier[property] = fmt.Sprint(value)
+			}
+		}
+		if len(identifier) == 0 {
+			return nil, fmt.Errorf("resource to import, %v, has no identifier", id)
+		}
+
+		imports = append(imports, cloudformation.ResourceToImport{
+			LogicalResourceId:  aws.String(id),
+			ResourceIdentifier: identifier,
+			ResourceType:       aws.String(typ),
+		})
+	}
+
+	return imports, nil
+}
+
+// importResources imports the resources of the stack that the deployed stack does not
+// yet contain using an IMPORT change set.  The change returned applies the remainder of
+// the template, as an update, once the resources have been imported.
+func (m *Manager) importResources(ctx context.Context, change Change) (Change, error) {
+	stack := change.Stack
+	if len(stack.ResourcesToImport) == 0 || change.Operation == Delete {
+		return change, nil
+	}
+
+	var current map[string]interface{}
+	if change.Operation == Update {
+		input := cloudformation.GetTemplateInput{
+			StackName: aws.String(stack.Name),
+		}
+		resp, err := m.api.GetTemplateRequest(&input).Send(ctx)
+		if err != nil {
+			return Change{}, fmt.Errorf("unable to import resources into stack, %v: %w", stack.Name, err)
+		}
+		current, err = parseTemplate(aws.StringValue(resp.TemplateBody))
+		if err != nil {
+			return Change{}, fmt.Errorf("unable to import resources into stack, %v: unable to parse current template: %w", stack.Name, err)
+		}
+	}
+
+	pending := pendingImports(current, stack.ResourcesToImport)
+	if len(pending) == 0 {
+		return change, nil
+	}
+
+	desired, err := parseTemplate(stack.TemplateBody)
+	if err != nil {
+		return Change{}, fmt.Errorf("unable to import resources into stack, %v: %w", stack.Name, err)
+	}
+	body, err := importTemplate(current, desired, pending)
+	if err != nil {
+		return Change{}, fmt.Errorf("unable to import resources into stack, %v: %w", stack.Name, err)
+	}
+
+	log.Printf("importing resources into stack, %v: %v\n", stack.Name, formatImports(pending))
+
+	if m.options.DryRun {
+		log.Printf("dry run.  import not applied for stack, %v\n", stack.Name)
+		return change, nil
+	}
+
+	importStack := stack
+	importStack.TemplateBody = body
+	importStack.Format = FormatJSON
+	importStack.ResourcesToImport = pending
+
+	plan, err := m.Plan(ctx, Change{Operation: Import, Stack: importStack})
+	if err != nil {
+		return Change{}, fmt.Errorf("unable to import resources into stack, %v: %w", stack.Name, err)
+	}
+	if err := m.Execute(ctx, plan); err != nil {
+		return Change{}, fmt.Errorf("unable to import resources into stack, %v: %w", stack.Name, err)
+	}
+
+	return Change{Operation: Update, Stack: stack}, nil
+}
+
+// pendingImports returns the resources not yet contained by the current template
+func pendingImports(current map[string]interface{}, imports []cloudformation.ResourceToImport) []cloudformation.ResourceToImport {
+	resources, _ := current["Resources"].(map[string]interface{})
+
+	var pending []cloudformation.ResourceToImport
+	for _, r := range imports {
+		if _, ok := resources[aws.StringValue(r.LogicalResourceId)]; ok {
+			continue
+		}
+		pending = append(pending, r)
+	}
+	return pending
+}
+
+// importTemplate returns the template for an IMPORT change set; the current template, if
+// the stack exists, plus the resources to import as declared by the desired template.
+// cloudformation permits no other changes in an import so the parameters, mappings, and
+// conditions of the desired template are only added where missing.
+func importTemplate(current, desired map[string]interface{}, imports []cloudformation.ResourceToImport) (string, error) {
+	template := map[string]interface{}{}
+	for k, v := range current {
+		template[k] = v
+	}
+	if v, ok := desired["AWSTemplateFormatVersion"]; ok {
+		template["AWSTemplateFormatVersion"] = v
+	}
+
+	for _, section := range []string{"Parameters", "Mappings", "Conditions", "Resources"} {
+		merged := map[string]interface{}{}
+		if v, ok := template[section].(map[string]interface{}); ok {
+			for k, item := range v {
+				merged[k] = item
+			}
+		}
+		if section != "Resources" {
+			if v, ok := desired[section].(map[string]interface{}); ok {
+				for k, item := range v {
+					if _, ok := merged[k]; !ok {
+						merged[k] = item
+					}
+				}
+			}
+		}
+		if len(merged) > 0 {
+			template[section] = merged
+		}
+	}
+
+	resources, _ := template["Resources"].(map[string]interface{})
+	if resources == nil {
+		resources = map[string]interface{}{}
+		template["Resources"] = resources
+	}
+	desiredResources, _ := desired["Resources"].(map[string]interface{})
+	for _, r := range imports {
+		id := aws.StringValue(r.LogicalResourceId)
+		resources[id] = desiredResources[id]
+	}
+
+	data, err := json.MarshalIndent(template, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("unable to encode import template: %w", err)
+	}
+	return string(data), nil
+}
+
+// formatImports renders the resources to import for logging
+func formatImports(imports []cloudformation.ResourceToImport) string {
+	var ss []string
+	for _, r := range imports {
+		var pairs []string
+		for k, v := range r.ResourceIdentifier {
+			pairs = append(pairs, k+"="+v)
+		}
+		sort.Strings(pairs)
+		ss = append(ss, fmt.Sprintf("%v (%v) %v", aws.StringValue(r.LogicalResourceId), aws.StringValue(r.ResourceType), strings.Join(pairs, ",")))
+	}
+	return strings.Join(ss, ", ")
+}
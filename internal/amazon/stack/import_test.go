@@ -0,0 +1,177 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+func TestLoad_import(t *testing.T) {
+	stacks, err := LoadAll("testdata/import", WithParameters(map[string]string{Env: "local"}))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(stacks), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	want := []cloudformation.ResourceToImport{
+		{
+			LogicalResourceId:  aws.String("Bucket"),
+			ResourceIdentifier: map[string]string{"BucketName": "local-assets"},
+			ResourceType:       aws.String("AWS::S3::Bucket"),
+		},
+		{
+			LogicalResourceId:  aws.String("Table"),
+			ResourceIdentifier: map[string]string{"TableName": "local-orders"},
+			ResourceType:       aws.String("AWS::DynamoDB::Table"),
+		},
+	}
+	if got := stacks[0].ResourcesToImport; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func Test_makeResourcesToImport(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/import/table.template")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	testCases := map[string]struct {
+		Mapping map[string]interface{}
+		Want    string
+	}{
+		"undeclared": {
+			Mapping: map[string]interface{}{"Topic": "arn:aws:sns:us-west-2:123456789012:events"},
+			Want:    "resource to import, Topic, is not declared by the template",
+		},
+		"not retained": {
+			Mapping: map[string]interface{}{"Queue": "https://sqs.us-west-2.amazonaws.com/123456789012/jobs"},
+			Want:    "resource to import, Queue, must set DeletionPolicy: Retain",
+		},
+		"no identifier": {
+			Mapping: map[string]interface{}{"Table": map[interface{}]interface{}{}},
+			Want:    "resource to import, Table, has no identifier",
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			_, err := makeResourcesToImport(string(data), tc.Mapping)
+			if err == nil {
+				t.Fatalf("got nil; want err")
+			}
+			if got := err.Error(); got != tc.Want {
+				t.Fatalf("got %v; want %v", got, tc.Want)
+			}
+		})
+	}
+}
+
+func Test_importTemplate(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/import/table.template")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	desired, err := parseTemplate(string(data))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	imports := []cloudformation.ResourceToImport{
+		{LogicalResourceId: aws.String("Table")},
+	}
+
+	testCases := map[string]struct {
+		Current       map[string]interface{}
+		WantResources []string
+		WantOutputs   bool
+	}{
+		"new stack": {
+			WantResources: []string{"Table"},
+		},
+		"existing stack": {
+			Current: map[string]interface{}{
+				"Resources": map[string]interface{}{
+					"Queue": map[string]interface{}{"Type": "AWS::SQS::Queue"},
+				},
+				"Outputs": map[string]interface{}{
+					"QueueUrl": map[string]interface{}{"Value": map[string]interface{}{"Ref": "Queue"}},
+				},
+			},
+			WantResources: []string{"Queue", "Table"},
+			WantOutputs:   true,
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			body, err := importTemplate(tc.Current, desired, imports)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			content, err := parseTemplate(body)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+
+			var got []string
+			for id := range content["Resources"].(map[string]interface{}) {
+				got = append(got, id)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.WantResources) {
+				t.Fatalf("got %v; want %v", got, tc.WantResources)
+			}
+			if _, ok := content["Parameters"].(map[string]interface{})["Env"]; !ok {
+				t.Fatalf("got false; want Env parameter")
+			}
+			if _, ok := content["Outputs"]; ok != tc.WantOutputs {
+				t.Fatalf("got %v; want %v", ok, tc.WantOutputs)
+			}
+			if !strings.Contains(body, `"DeletionPolicy": "Retain"`) {
+				t.Fatalf("got %v; want DeletionPolicy Retain", body)
+			}
+		})
+	}
+}
+
+func Test_pendingImports(t *testing.T) {
+	current := map[string]interface{}{
+		"Resources": map[string]interface{}{
+			"Table": map[string]interface{}{"Type": "AWS::DynamoDB::Table"},
+		},
+	}
+	imports := []cloudformation.ResourceToImport{
+		{LogicalResourceId: aws.String("Bucket")},
+		{LogicalResourceId: aws.String("Table")},
+	}
+
+	got := pendingImports(current, imports)
+	if len(got) != 1 || aws.StringValue(got[0].LogicalResourceId) != "Bucket" {
+		t.Fatalf("got %v; want Bucket", got)
+	}
+	if got := pendingImports(nil, imports); len(got) != 2 {
+		t.Fatalf("got %v; want 2", len(got))
+	}
+}
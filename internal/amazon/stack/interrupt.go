@@ -0,0 +1,175 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+var (
+	// ErrInterrupted is returned when a stack operation is abandoned because its context
+	// was cancelled e.g. on SIGINT
+	ErrInterrupted = errors.New("stack operation interrupted")
+	// ErrTimeout is returned when a stack operation does not finish within its timeout
+	ErrTimeout = errors.New("stack operation timed out")
+)
+
+// TimeoutAction determines what becomes of a stack create or update that exceeds its
+// timeout
+type TimeoutAction string
+
+const (
+	// TimeoutCancel cancels the update so the stack rolls back, or deletes the stack being
+	// created
+	TimeoutCancel TimeoutAction = "cancel"
+	// TimeoutContinue leaves the create or update running
+	TimeoutContinue TimeoutAction = "continue"
+)
+
+// expireTimeout bounds the time taken to clean up after a stack operation that timed out
+// or failed
+const expireTimeout = time.Minute
+
+// settleTimeout bounds the time an interrupted stack operation is given to settle
+const settleTimeout = time.Hour
+
+// Interruption records the status of a stack once its interrupted operation settled or,
+// for operations that timed out, when the timeout expired
+type Interruption struct {
+	StackName string
+	Operation Operation
+	Status    cloudformation.StackStatus
+	// Cause holds either ErrInterrupted or ErrTimeout
+	Cause error
+}
+
+func (i Interruption) String() string {
+	what := "interrupted"
+	if errors.Is(i.Cause, ErrTimeout) {
+		what = "timed out"
+	}
+	if i.Status == "" {
+		return fmt.Sprintf("%v of stack, %v, %v; final status unknown", i.Operation, i.StackName, what)
+	}
+	return fmt.Sprintf("%v of stack, %v, %v; stack is %v", i.Operation, i.StackName, what, i.Status)
+}
+
+// Interrupted returns the stack operations interrupted by cancellation of their context
+// or by their timeout
+func (m *Manager) Interrupted() []Interruption {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([]Interruption(nil), m.interrupted...)
+}
+
+// interrupt settles a stack operation whose context was cancelled or whose timeout
+// expired.  When interrupted, updates are cancelled and rolled back.  Creates and deletes
+// cannot be cancelled so they are waited on; a create that is interrupted completes or
+// rolls back as it would have otherwise.  The final status of the stack is recorded for
+// Interrupted.
+func (m *Manager) interrupt(parent context.Context, stackName string, operation Operation) error {
+	if errors.Is(parent.Err(), context.DeadlineExceeded) {
+		return m.expire(stackName, operation)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), settleTimeout)
+	defer cancel()
+
+	if operation == Update {
+		log.Printf("interrupted.  cancelling update of stack, %v\n", stackName)
+		input := cloudformation.CancelUpdateStackInput{
+			StackName: aws.String(stackName),
+		}
+		if _, err := m.api.CancelUpdateStackRequest(&input).Send(ctx); err != nil {
+			log.Printf("unable to cancel update of stack, %v: %v\n", stackName, err)
+		}
+	} else {
+		log.Printf("interrupted.  waiting for %v of stack, %v, to finish\n", operation, stackName)
+	}
+
+	status, err := m.waitForStack(ctx, stackName)
+	if err != nil {
+		log.Printf("unable to determine status of stack, %v: %v\n", stackName, err)
+	}
+
+	return m.recordInterruption(Interruption{
+		StackName: stackName,
+		Operation: operation,
+		Status:    status,
+		Cause:     ErrInterrupted,
+	})
+}
+
+// expire handles a stack operation that exceeded its timeout.  Unless configured to
+// continue, updates are cancelled and stacks being created are deleted rather than left to
+// the TimeoutInMinutes they were created with, which cloudformation applies only once the
+// create has run for whole minutes.  Deletes are left running.  The stack is reported as
+// is rather than waited on.
+func (m *Manager) expire(stackName string, operation Operation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), expireTimeout)
+	defer cancel()
+
+	switch {
+	case operation == Update && m.options.TimeoutAction != TimeoutContinue:
+		log.Printf("timed out.  cancelling update of stack, %v\n", stackName)
+		input := cloudformation.CancelUpdateStackInput{
+			StackName: aws.String(stackName),
+		}
+		if _, err := m.api.CancelUpdateStackRequest(&input).Send(ctx); err != nil {
+			log.Printf("unable to cancel update of stack, %v: %v\n", stackName, err)
+		}
+	case operation == Insert && m.options.TimeoutAction != TimeoutContinue:
+		log.Printf("timed out.  deleting stack, %v, to roll back its create\n", stackName)
+		input := cloudformation.DeleteStackInput{
+			StackName: aws.String(stackName),
+		}
+		if _, err := m.api.DeleteStackRequest(&input).Send(ctx); err != nil {
+			log.Printf("unable to delete stack, %v: %v\n", stackName, err)
+		}
+	default:
+		log.Printf("timed out.  leaving %v of stack, %v, running\n", operation, stackName)
+	}
+
+	status, err := m.stackStatus(ctx, stackName)
+	if err != nil {
+		log.Printf("unable to determine status of stack, %v: %v\n", stackName, err)
+	}
+
+	return m.recordInterruption(Interruption{
+		StackName: stackName,
+		Operation: operation,
+		Status:    status,
+		Cause:     ErrTimeout,
+	})
+}
+
+// recordInterruption records the interruption for Interrupted and returns it as an error
+func (m *Manager) recordInterruption(interruption Interruption) error {
+	log.Println(interruption)
+
+	m.mutex.Lock()
+	m.interrupted = append(m.interrupted, interruption)
+	m.mutex.Unlock()
+
+	return fmt.Errorf("%w: %v", interruption.Cause, interruption)
+}
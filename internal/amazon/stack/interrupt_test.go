@@ -0,0 +1,292 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+func TestInterruption_String(t *testing.T) {
+	testCases := map[string]struct {
+		Interruption Interruption
+		Want         string
+	}{
+		"update": {
+			Interruption: Interruption{StackName: "app", Operation: Update, Status: cloudformation.StackStatusUpdateRollbackComplete, Cause: ErrInterrupted},
+			Want:         "update of stack, app, interrupted; stack is UPDATE_ROLLBACK_COMPLETE",
+		},
+		"timeout": {
+			Interruption: Interruption{StackName: "app", Operation: Insert, Status: cloudformation.StackStatusCreateInProgress, Cause: ErrTimeout},
+			Want:         "insert of stack, app, timed out; stack is CREATE_IN_PROGRESS",
+		},
+		"unknown": {
+			Interruption: Interruption{StackName: "app", Operation: Insert, Cause: ErrInterrupted},
+			Want:         "insert of stack, app, interrupted; final status unknown",
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			if got := tc.Interruption.String(); got != tc.Want {
+				t.Fatalf("got %v; want %v", got, tc.Want)
+			}
+		})
+	}
+}
+
+func TestManager_interrupt(t *testing.T) {
+	testCases := map[Operation]struct {
+		Status cloudformation.StackStatus
+		Cancel bool
+	}{
+		Insert: {Status: cloudformation.StackStatusCreateComplete},
+		Update: {Status: cloudformation.StackStatusUpdateRollbackComplete, Cancel: true},
+		Delete: {Status: cloudformation.StackStatusDeleteComplete},
+	}
+
+	for operation, tc := range testCases {
+		t.Run(string(operation), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			api := &stubAPI{StackStatus: tc.Status}
+			manager := New(api)
+			err := manager.interrupt(ctx, "app", operation)
+			if !errors.Is(err, ErrInterrupted) {
+				t.Fatalf("got %v; want %v", err, ErrInterrupted)
+			}
+			if got, want := api.Called("CancelUpdateStack"), tc.Cancel; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+			if got, want := api.Called("DeleteStack"), false; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+
+			want := Interruption{StackName: "app", Operation: operation, Status: tc.Status, Cause: ErrInterrupted}
+			if got := manager.Interrupted(); len(got) != 1 || got[0] != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestManager_expire(t *testing.T) {
+	testCases := map[string]struct {
+		Operation Operation
+		Action    TimeoutAction
+		Cancel    bool
+		Delete    bool
+	}{
+		"cancel update": {
+			Operation: Update,
+			Action:    TimeoutCancel,
+			Cancel:    true,
+		},
+		"continue update": {
+			Operation: Update,
+			Action:    TimeoutContinue,
+		},
+		"cancel insert": {
+			Operation: Insert,
+			Action:    TimeoutCancel,
+			Delete:    true,
+		},
+		"continue insert": {
+			Operation: Insert,
+			Action:    TimeoutContinue,
+		},
+		"cancel delete": {
+			Operation: Delete,
+			Action:    TimeoutCancel,
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 0)
+			defer cancel()
+			<-ctx.Done()
+
+			api := &stubAPI{StackStatus: cloudformation.StackStatusUpdateInProgress}
+			manager := New(api, WithTimeout(0, tc.Action))
+			err := manager.interrupt(ctx, "app", tc.Operation)
+			if !errors.Is(err, ErrTimeout) {
+				t.Fatalf("got %v; want %v", err, ErrTimeout)
+			}
+			if got, want := api.Called("CancelUpdateStack"), tc.Cancel; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+			if got, want := api.Called("DeleteStack"), tc.Delete; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+
+			want := Interruption{StackName: "app", Operation: tc.Operation, Status: cloudformation.StackStatusUpdateInProgress, Cause: ErrTimeout}
+			if got := manager.Interrupted(); len(got) != 1 || got[0] != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestManager_Execute_interrupted(t *testing.T) {
+	plan := Plan{Operation: Update, StackName: "app", ChangeSetName: "change-set"}
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		api := &stubAPI{
+			StackStatus: cloudformation.StackStatusUpdateRollbackComplete,
+			WaitErr:     context.Canceled,
+		}
+		manager := New(api)
+		err := manager.Execute(ctx, plan)
+		if !errors.Is(err, ErrInterrupted) {
+			t.Fatalf("got %v; want %v", err, ErrInterrupted)
+		}
+		if !api.Called("CancelUpdateStack") {
+			t.Fatalf("got false; want true")
+		}
+		if got := manager.Failures(); len(got) != 0 {
+			t.Fatalf("got %v; want no failures", got)
+		}
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		api := &stubAPI{
+			StackStatus: cloudformation.StackStatusUpdateRollbackComplete,
+			WaitErr:     errors.New("failed"),
+		}
+		manager := New(api)
+		err := manager.Execute(context.Background(), plan)
+		var fe *FailureError
+		if !errors.As(err, &fe) {
+			t.Fatalf("got %v; want FailureError", err)
+		}
+		if api.Called("CancelUpdateStack") {
+			t.Fatalf("got true; want false")
+		}
+		if got := manager.Interrupted(); len(got) != 0 {
+			t.Fatalf("got %v; want no interruptions", got)
+		}
+	})
+}
+
+func TestManager_Delete_interrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	api := &stubAPI{
+		StackStatus: cloudformation.StackStatusDeleteComplete,
+		WaitErr:     context.Canceled,
+	}
+	manager := New(api)
+	err := manager.Delete(ctx, "app")
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("got %v; want %v", err, ErrInterrupted)
+	}
+	if api.Called("CancelUpdateStack") {
+		t.Fatalf("got true; want false")
+	}
+	if got := manager.Failures(); len(got) != 0 {
+		t.Fatalf("got %v; want no failures", got)
+	}
+	if got, want := len(manager.Interrupted()), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestManager_Create_timeout(t *testing.T) {
+	s, err := LoadFile("testdata/a/table.template")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	s.Name = "app"
+	s.Timeout = 90 * time.Second
+
+	t.Run("cancel", func(t *testing.T) {
+		api := &stubAPI{StackStatus: cloudformation.StackStatusCreateComplete}
+		if err := New(api, WithTimeout(time.Hour, TimeoutCancel)).Create(context.Background(), s); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if api.Called("CreateChangeSet") {
+			t.Fatalf("got true; want false")
+		}
+		if api.createStack == nil {
+			t.Fatalf("got nil; want CreateStack input")
+		}
+		if got, want := aws.Int64Value(api.createStack.TimeoutInMinutes), int64(2); got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if remaining := time.Until(api.waitDeadline); remaining <= 0 || remaining > s.Timeout {
+			t.Fatalf("got %v; want wait bounded by the stack timeout", remaining)
+		}
+	})
+
+	t.Run("continue", func(t *testing.T) {
+		api := &stubAPI{
+			ChangeSetStatus: cloudformation.ChangeSetStatusCreateComplete,
+			StackStatus:     cloudformation.StackStatusCreateComplete,
+		}
+		if err := New(api, WithTimeout(time.Hour, TimeoutContinue)).Create(context.Background(), s); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if api.Called("CreateStack") {
+			t.Fatalf("got true; want false")
+		}
+		if !api.Called("ExecuteChangeSet") {
+			t.Fatalf("got false; want true")
+		}
+	})
+}
+
+func TestManager_deleteStack_timeout(t *testing.T) {
+	api := &stubAPI{StackStatus: cloudformation.StackStatusDeleteComplete}
+	manager := New(api, WithTimeout(time.Hour, TimeoutCancel))
+
+	if err := manager.deleteStack(context.Background(), Stack{Name: "app", Timeout: time.Minute}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if remaining := time.Until(api.waitDeadline); remaining <= 0 || remaining > time.Minute {
+		t.Fatalf("got %v; want wait bounded by the stack timeout", remaining)
+	}
+
+	if err := manager.Delete(context.Background(), "app"); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if remaining := time.Until(api.waitDeadline); remaining <= time.Minute || remaining > time.Hour {
+		t.Fatalf("got %v; want wait bounded by the default timeout", remaining)
+	}
+}
+
+func Test_timeoutInMinutes(t *testing.T) {
+	testCases := map[time.Duration]int64{
+		time.Second:      1,
+		time.Minute:      1,
+		90 * time.Second: 2,
+		time.Hour:        60,
+	}
+	for timeout, want := range testCases {
+		if got := timeoutInMinutes(timeout); got != want {
+			t.Fatalf("%v: got %v; want %v", timeout, got, want)
+		}
+	}
+}
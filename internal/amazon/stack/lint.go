@@ -0,0 +1,257 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	gfcloudformation "github.com/awslabs/goformation/v4/cloudformation"
+)
+
+// unmarshalerType is used to identify polymorphic property types whose fields cannot be
+// checked by name
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// Lint checks the template of the stack for problems that can be found without calling
+// cloudformation; unknown resource types, unknown properties, and Ref, Fn::GetAtt, and
+// Fn::Sub references to undeclared parameters or resources.  References are not checked
+// for templates that declare a Transform as the transform may add resources.
+func Lint(stack Stack) ([]string, error) {
+	content, err := parseTemplate(stack.TemplateBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, _ := content["Resources"].(map[string]interface{})
+	if len(resources) == 0 {
+		return []string{"template declares no resources"}, nil
+	}
+
+	var (
+		problems []string
+		types    = gfcloudformation.AllResources()
+	)
+	for logicalID, v := range resources {
+		resource, _ := v.(map[string]interface{})
+		resourceType := resourceType(v)
+		if resourceType == "" {
+			problems = append(problems, fmt.Sprintf("resource, %v, does not declare a Type", logicalID))
+			continue
+		}
+		if strings.HasPrefix(resourceType, "Custom::") {
+			continue
+		}
+
+		typed, ok := types[resourceType]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("resource, %v, has unknown type, %v", logicalID, resourceType))
+			continue
+		}
+
+		path := "resource, " + logicalID + ", "
+		problems = append(problems, checkProperties(path, reflect.TypeOf(typed), resource["Properties"])...)
+	}
+
+	if _, ok := content["Transform"]; !ok {
+		problems = append(problems, checkReferences(content)...)
+	}
+
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// ValidateTemplate asks cloudformation to validate the template of the stack
+func (m *Manager) ValidateTemplate(ctx context.Context, stack Stack) error {
+	body, url, err := m.templateSource(ctx, stack)
+	if err != nil {
+		return fmt.Errorf("unable to validate template for stack, %v: %w", stack.Name, err)
+	}
+
+	input := cloudformation.ValidateTemplateInput{
+		TemplateBody: body,
+		TemplateURL:  url,
+	}
+	if _, err := m.api.ValidateTemplateRequest(&input).Send(ctx); err != nil {
+		return fmt.Errorf("invalid template for stack, %v: %w", stack.Name, err)
+	}
+
+	return nil
+}
+
+// checkProperties verifies the names of the properties in v are declared by the struct t
+func checkProperties(path string, t reflect.Type, v interface{}) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if isIntrinsic(value) {
+			return nil
+		}
+
+		switch t.Kind() {
+		case reflect.Map:
+			var problems []string
+			for k, item := range value {
+				problems = append(problems, checkProperties(path+"property, "+k+", ", t.Elem(), item)...)
+			}
+			return problems
+
+		case reflect.Struct:
+			if reflect.PtrTo(t).Implements(unmarshalerType) && !isResourceType(t) {
+				return nil
+			}
+
+			fields := map[string]reflect.Type{}
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				name := strings.Split(f.Tag.Get("json"), ",")[0]
+				if name == "" || name == "-" {
+					continue
+				}
+				fields[name] = f.Type
+			}
+
+			var problems []string
+			for k, item := range value {
+				ft, ok := fields[k]
+				if !ok {
+					problems = append(problems, fmt.Sprintf("%vhas unknown property, %v", path, k))
+					continue
+				}
+				problems = append(problems, checkProperties(path+"property, "+k+", ", ft, item)...)
+			}
+			return problems
+		}
+
+	case []interface{}:
+		if t.Kind() != reflect.Slice {
+			return nil
+		}
+		var problems []string
+		for _, item := range value {
+			problems = append(problems, checkProperties(path, t.Elem(), item)...)
+		}
+		return problems
+	}
+
+	return nil
+}
+
+// isResourceType returns true if t is a top level goformation resource
+func isResourceType(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(reflect.TypeOf((*gfcloudformation.Resource)(nil)).Elem())
+}
+
+// isIntrinsic returns true if the object is an intrinsic function e.g. {"Ref": "Name"}
+func isIntrinsic(m map[string]interface{}) bool {
+	if len(m) != 1 {
+		return false
+	}
+	for k := range m {
+		return k == "Ref" || k == "Condition" || strings.HasPrefix(k, "Fn::")
+	}
+	return false
+}
+
+// checkReferences verifies that Ref, Fn::GetAtt, and Fn::Sub refer to declared
+// parameters, resources, or pseudo parameters
+func checkReferences(content map[string]interface{}) []string {
+	parameters, _ := content["Parameters"].(map[string]interface{})
+	resources, _ := content["Resources"].(map[string]interface{})
+
+	isDeclared := func(name string) bool {
+		_, isParameter := parameters[name]
+		_, isResource := resources[name]
+		return isParameter || isResource || strings.HasPrefix(name, "AWS::")
+	}
+	isResource := func(name string) bool {
+		_, ok := resources[name]
+		return ok
+	}
+
+	var problems []string
+	walk(content, func(m map[string]interface{}) {
+		if len(m) != 1 {
+			return
+		}
+
+		if ref, ok := m["Ref"].(string); ok && !isDeclared(ref) {
+			problems = append(problems, fmt.Sprintf("Ref refers to undeclared parameter or resource, %v", ref))
+		}
+
+		if v, ok := m["Fn::GetAtt"]; ok {
+			var name string
+			switch value := v.(type) {
+			case string:
+				name = strings.SplitN(value, ".", 2)[0]
+			case []interface{}:
+				if len(value) > 0 {
+					name, _ = value[0].(string)
+				}
+			}
+			if name != "" && !isResource(name) {
+				problems = append(problems, fmt.Sprintf("Fn::GetAtt refers to undeclared resource, %v", name))
+			}
+		}
+
+		if v, ok := m["Fn::Sub"]; ok {
+			var (
+				text   string
+				locals map[string]interface{}
+			)
+			switch value := v.(type) {
+			case string:
+				text = value
+			case []interface{}:
+				if len(value) == 2 {
+					text, _ = value[0].(string)
+					locals, _ = value[1].(map[string]interface{})
+				}
+			}
+			for _, match := range reSubVariable.FindAllStringSubmatch(text, -1) {
+				name := match[1]
+				if _, ok := locals[name]; ok {
+					continue
+				}
+				if parts := strings.SplitN(name, ".", 2); len(parts) == 2 && !strings.HasPrefix(name, "AWS::") {
+					if !isResource(parts[0]) {
+						problems = append(problems, fmt.Sprintf("Fn::Sub refers to undeclared resource, %v", parts[0]))
+					}
+					continue
+				}
+				if !isDeclared(name) {
+					problems = append(problems, fmt.Sprintf("Fn::Sub refers to undeclared parameter or resource, %v", name))
+				}
+			}
+		}
+	})
+
+	return appendUniqueAll(nil, problems)
+}
+
+func appendUniqueAll(ss []string, items []string) []string {
+	for _, item := range items {
+		ss = appendUnique(ss, item)
+	}
+	return ss
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		stacks, err := LoadAll("testdata/imports", WithParameters(map[string]string{Env: "local"}))
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		for _, s := range stacks {
+			problems, err := Lint(s)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			if len(problems) > 0 {
+				t.Fatalf("got %v; want none for stack, %v", problems, s.Name)
+			}
+		}
+	})
+
+	t.Run("broken", func(t *testing.T) {
+		data, err := ioutil.ReadFile("testdata/lint/broken.template")
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		problems, err := Lint(Stack{Name: "broken", TemplateBody: string(data)})
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		want := []string{
+			"Fn::GetAtt refers to undeclared resource, Quue",
+			"Fn::Sub refers to undeclared parameter or resource, Project",
+			"Fn::Sub refers to undeclared resource, Bucket",
+			"Ref refers to undeclared parameter or resource, Envv",
+			"resource, Queue, has unknown type, AWS::SQS::Queueue",
+			"resource, Table, has unknown property, BilingMode",
+			"resource, Table, property, KeySchema, has unknown property, KeyTyp",
+		}
+		if !reflect.DeepEqual(problems, want) {
+			t.Fatalf("got %v; want %v", problems, want)
+		}
+	})
+}
@@ -20,15 +20,17 @@ import (
 	"fmt"
 	"log"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/awserr"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation/cloudformationiface"
-	gf "github.com/awslabs/goformation/v4"
-	"github.com/sanathkr/go-yaml"
+	"github.com/fatih/color"
 )
 
 const (
@@ -36,16 +38,30 @@ const (
 	errValidationError  = "ValidationError"
 )
 
+// changeSetDelay is the interval between polls while a change set is being created
+const changeSetDelay = 5 * time.Second
+
 type Manager struct {
 	api     cloudformationiface.ClientAPI
 	options Options
+	console *Console // shared by every stack so concurrent output does not interleave
+
+	mutex       sync.Mutex
+	outputs     map[string]string // outputs of applied stacks, published as parameters
+	failures    []*FailureError   // failed stack operations, in the order they failed
+	interrupted []Interruption    // stack operations interrupted, in the order they settled
 }
 
 func New(api cloudformationiface.ClientAPI, opts ...Option) *Manager {
 	options := buildOptions(opts...)
+	console := options.Console
+	if console == nil {
+		console = NewConsole(color.Output, options.Concurrency > 1)
+	}
 	return &Manager{
 		api:     api,
 		options: options,
+		console: console,
 	}
 }
 
@@ -58,37 +74,77 @@ func (m *Manager) Apply(ctx context.Context, changes ...Change) (err error) {
 		)
 	}(time.Now())
 
-	// apply all deletes first in reverse order, FILO
-	for i := len(changes) - 1; i >= 0; i-- {
-		change := changes[i]
-		if change.Operation != Delete {
-			continue
+	if err := m.Validate(ctx, upsertStacks(changes)...); err != nil {
+		return fmt.Errorf("failed to apply changes: %w", err)
+	}
+
+	changes, err = m.guardDeletes(ctx, changes)
+	if err != nil {
+		return fmt.Errorf("failed to apply changes: %w", err)
+	}
+
+	changes, err = m.linkDeletes(ctx, changes)
+	if err != nil {
+		return fmt.Errorf("failed to apply changes: %w", err)
+	}
+
+	var deletes, upserts []Change
+	for _, change := range changes {
+		if change.Operation == Delete {
+			deletes = append(deletes, change)
+		} else {
+			upserts = append(upserts, change)
 		}
+	}
 
-		if err := m.Delete(ctx, change.Stack.Name); err != nil {
-			return fmt.Errorf("failed to apply changes: %w", err)
+	// apply all deletes first in reverse order, FILO
+	deletes = reverse(deletes)
+	deleteFn := func(ctx context.Context, i int) error {
+		change, err := m.recover(ctx, deletes[i])
+		if err != nil {
+			return err
+		}
+		if change.Status == cloudformation.StackStatusDeleteComplete {
+			return nil
 		}
+		return m.deleteStack(ctx, change.Stack)
+	}
+	if err := schedule(ctx, m.options.Concurrency, dependentNodes(deletes), deleteFn); err != nil {
+		return fmt.Errorf("failed to apply changes: %w", err)
 	}
 
 	// apply all inserts and updates
-	for _, change := range changes {
+	upsertFn := func(ctx context.Context, i int) error {
+		change, err := m.recover(ctx, upserts[i])
+		if err != nil {
+			return err
+		}
+		change, err = m.importResources(ctx, change)
+		if err != nil {
+			return err
+		}
+
 		switch change.Operation {
 		case Insert:
-			if err := m.Create(ctx, change.Stack); err != nil {
-				return fmt.Errorf("failed to apply changes: %w", err)
-			}
+			err = m.Create(ctx, change.Stack)
 		case Update:
-			if err := m.Update(ctx, change.Stack); err != nil {
-				return fmt.Errorf("failed to apply changes: %w", err)
-			}
+			err = m.Update(ctx, change.Stack)
+		}
+		if err != nil || m.options.DryRun {
+			return err
 		}
+
+		return m.collectOutputs(ctx, change.Stack)
+	}
+	if err := schedule(ctx, m.options.Concurrency, dependencyNodes(upserts), upsertFn); err != nil {
+		return fmt.Errorf("failed to apply changes: %w", err)
 	}
 
 	return nil
 }
 
 func (m *Manager) Create(ctx context.Context, stack Stack) (err error) {
-	ctx, cancel := context.WithCancel(ctx)
+	ctx, cancel := withTimeout(ctx, m.timeout(stack))
 	defer cancel()
 
 	defer func(begin time.Time) {
@@ -98,54 +154,44 @@ func (m *Manager) Create(ctx context.Context, stack Stack) (err error) {
 			err,
 		)
 	}(time.Now())
-	defer func() {
-		if errors.Is(err, context.Canceled) {
-			err = nil
-		}
-	}()
-
-	params, err := getParameters(stack.TemplateBody, m.options.Parameters)
-	if err != nil {
-		return fmt.Errorf("failed to create stack, %v: %w", stack.Name, err)
-	}
 
 	if m.options.DryRun {
 		log.Printf("dry run.  create not applied for stack, %v - %v\n", stack.Name, err)
 		return nil
 	}
 
-	input := cloudformation.CreateStackInput{
-		Capabilities: []cloudformation.Capability{
-			cloudformation.CapabilityCapabilityNamedIam,
-		},
-		Parameters:   params,
-		StackName:    aws.String(stack.Name),
-		Tags:         append(m.options.Tags[0:len(m.options.Tags):len(m.options.Tags)], stack.Tags...),
-		TemplateBody: aws.String(stack.TemplateBody),
-	}
-	req := m.api.CreateStackRequest(&input)
-	_, err = req.Send(ctx)
-	if err != nil {
-		return fmt.Errorf("unable to create stack, %v: %w", stack.Name, err)
-	}
-
-	go func() {
-		defer cancel()
-		observeEvents(ctx, m.api, stack.Name)
-	}()
+	if timeout := m.timeout(stack); timeout > 0 && m.options.TimeoutAction != TimeoutContinue {
+		if err := m.createStack(ctx, stack, timeout); err != nil {
+			return fmt.Errorf("failed to create stack, %v: %w", stack.Name, err)
+		}
+	} else {
+		plan, err := m.Plan(ctx, Change{Operation: Insert, Stack: stack})
+		if err != nil {
+			return fmt.Errorf("failed to create stack, %v: %w", stack.Name, err)
+		}
 
-	describeInput := cloudformation.DescribeStacksInput{
-		StackName: aws.String(stack.Name),
+		if err := m.Execute(ctx, plan); err != nil {
+			return fmt.Errorf("failed to create stack, %v: %w", stack.Name, err)
+		}
 	}
-	if err := m.api.WaitUntilStackCreateComplete(ctx, &describeInput); err != nil {
-		return fmt.Errorf("failed while waiting for complete to finish for stack, %v: %w", stack.Name, err)
+
+	if err := m.configure(ctx, stack); err != nil {
+		return fmt.Errorf("failed to create stack, %v: %w", stack.Name, err)
 	}
 
 	return nil
 }
 
-func (m *Manager) Delete(ctx context.Context, stackName string) (err error) {
-	ctx, cancel := context.WithCancel(ctx)
+func (m *Manager) Delete(ctx context.Context, stackName string) error {
+	return m.deleteStack(ctx, Stack{Name: stackName})
+}
+
+// deleteStack deletes the stack within the timeout of the stack, if known, otherwise the
+// default timeout
+func (m *Manager) deleteStack(ctx context.Context, stack Stack) (err error) {
+	stackName := stack.Name
+
+	ctx, cancel := withTimeout(ctx, m.timeout(stack))
 	defer cancel()
 
 	defer func(begin time.Time) {
@@ -155,17 +201,18 @@ func (m *Manager) Delete(ctx context.Context, stackName string) (err error) {
 			err,
 		)
 	}(time.Now())
-	defer func() {
-		if errors.Is(err, context.Canceled) {
-			err = nil
-		}
-	}()
 
 	if m.options.DryRun {
 		log.Printf("dry run.  delete not applied for stack, %v - %v\n", stackName, err)
 		return nil
 	}
 
+	if m.options.ForceDelete {
+		if err := m.disableTerminationProtection(ctx, stackName); err != nil {
+			return fmt.Errorf("failed to delete stack, %v: %w", stackName, err)
+		}
+	}
+
 	req := m.api.DeleteStackRequest(&cloudformation.DeleteStackInput{
 		StackName: aws.String(stackName),
 	})
@@ -173,20 +220,149 @@ func (m *Manager) Delete(ctx context.Context, stackName string) (err error) {
 		return fmt.Errorf("failed to delete stack, %v: %w", stackName, err)
 	}
 
+	waitCtx, cancelWait := context.WithCancel(ctx)
+	defer cancelWait()
+
+	go func() {
+		defer cancelWait()
+		m.observe(waitCtx, stackName)
+	}()
+
+	describeInput := cloudformation.DescribeStacksInput{
+		StackName: aws.String(stackName),
+	}
+	if err := m.wait(ctx, waitCtx, stackName, m.api.WaitUntilStackDeleteComplete(waitCtx, &describeInput)); err != nil {
+		if ctx.Err() != nil {
+			return m.interrupt(ctx, stackName, Delete)
+		}
+		return m.failureError(ctx, stackName, fmt.Errorf("failed while waiting for delete to finish for stack, %v: %w", stackName, err))
+	}
+	return nil
+}
+
+// Execute the change set described by the plan and wait for the stack operation to complete
+func (m *Manager) Execute(ctx context.Context, plan Plan) error {
+	if plan.ChangeSetName == "" {
+		return nil
+	}
+
+	input := cloudformation.ExecuteChangeSetInput{
+		ChangeSetName: aws.String(plan.ChangeSetName),
+		StackName:     aws.String(plan.StackName),
+	}
+	if _, err := m.api.ExecuteChangeSetRequest(&input).Send(ctx); err != nil {
+		m.abandon(plan)
+		return fmt.Errorf("unable to execute change set, %v, for stack, %v: %w", plan.ChangeSetName, plan.StackName, err)
+	}
+
+	return m.await(ctx, plan.Operation, plan.StackName)
+}
+
+// createStack creates the stack directly rather than from a change set, as change sets
+// offer no TimeoutInMinutes, so that cloudformation rolls the create back once it exceeds
+// its timeout even should fairy no longer be running.  The plan reported lists the
+// resources of the template.
+func (m *Manager) createStack(ctx context.Context, stack Stack, timeout time.Duration) error {
+	params, err := m.parameters(ctx, stack)
+	if err != nil {
+		return err
+	}
+
+	body, url, err := m.templateSource(ctx, stack)
+	if err != nil {
+		return err
+	}
+
+	capabilities, err := stackCapabilities(stack)
+	if err != nil {
+		return err
+	}
+
+	plan, err := insertPlan(stack)
+	if err != nil {
+		return err
+	}
+	m.report(plan)
+
+	input := cloudformation.CreateStackInput{
+		Capabilities:     capabilities,
+		NotificationARNs: stack.NotificationARNs,
+		Parameters:       params,
+		StackName:        aws.String(stack.Name),
+		Tags:             mergeTags(m.options.Tags, stack.Tags),
+		TemplateBody:     body,
+		TemplateURL:      url,
+		TimeoutInMinutes: aws.Int64(timeoutInMinutes(timeout)),
+	}
+	if _, err := m.api.CreateStackRequest(&input).Send(ctx); err != nil {
+		return fmt.Errorf("unable to create stack, %v: %w", stack.Name, err)
+	}
+
+	return m.await(ctx, Insert, stack.Name)
+}
+
+// timeoutInMinutes rounds the timeout up to whole minutes, as cloudformation requires
+func timeoutInMinutes(timeout time.Duration) int64 {
+	minutes := int64((timeout + time.Minute - 1) / time.Minute)
+	if minutes < 1 {
+		return 1
+	}
+	return minutes
+}
+
+// await waits for the stack operation to complete.  Operations whose context ends first
+// are interrupted; operations that fail are described by a FailureError.
+func (m *Manager) await(ctx context.Context, operation Operation, stackName string) error {
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	go func() {
 		defer cancel()
-		observeEvents(ctx, m.api, stackName)
+		m.observe(waitCtx, stackName)
 	}()
 
 	describeInput := cloudformation.DescribeStacksInput{
 		StackName: aws.String(stackName),
 	}
-	if err := m.api.WaitUntilStackUpdateComplete(ctx, &describeInput); err != nil {
-		var ae awserr.Error
-		if ok := errors.As(err, &ae); ok && ae.Code() == errResourceNotReady {
-			return nil
+	var err error
+	switch operation {
+	case Insert:
+		if err = m.wait(ctx, waitCtx, stackName, m.api.WaitUntilStackCreateComplete(waitCtx, &describeInput)); err != nil {
+			err = fmt.Errorf("failed while waiting for create to finish for stack, %v: %w", stackName, err)
+		}
+	case Import:
+		if err = m.wait(ctx, waitCtx, stackName, m.api.WaitUntilStackImportComplete(waitCtx, &describeInput)); err != nil {
+			err = fmt.Errorf("failed while waiting for import to finish for stack, %v: %w", stackName, err)
+		}
+	default:
+		if err = m.wait(ctx, waitCtx, stackName, m.api.WaitUntilStackUpdateComplete(waitCtx, &describeInput)); err != nil {
+			err = fmt.Errorf("failed while waiting for update to finish for stack, %v: %w", stackName, err)
 		}
-		return fmt.Errorf("failed while waiting for delete to finish for stack, %v: %w", stackName, err)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return m.interrupt(ctx, stackName, operation)
+		}
+		return m.failureError(ctx, stackName, err)
+	}
+
+	return nil
+}
+
+// wait interprets the result of a waiter.  The event observer cuts the wait short once it
+// sees the stack operation end so the final status of the stack is checked to determine
+// whether the operation succeeded.
+func (m *Manager) wait(ctx, waitCtx context.Context, stackName string, err error) error {
+	if err != nil && (ctx.Err() != nil || waitCtx.Err() == nil) {
+		return err
+	}
+
+	status, err := m.stackStatus(ctx, stackName)
+	if err != nil {
+		return err
+	}
+	if isFailed(status) {
+		return fmt.Errorf("stack, %v, is %v", stackName, status)
 	}
 	return nil
 }
@@ -211,16 +387,33 @@ func (m *Manager) Exports(ctx context.Context) ([]cloudformation.Export, error)
 	return exports, nil
 }
 
+// List returns the summaries of the stacks managed by this manager.  When owner tags
+// are configured, only stacks carrying every owner tag are returned; otherwise stacks
+// are selected by prefix.
 func (m *Manager) List(ctx context.Context) (summaries []cloudformation.StackSummary, err error) {
 	defer func(begin time.Time) {
-		log.Printf("retrieved %v stack summaries, (%v, prefix: %v) - %v\n",
+		log.Printf("retrieved %v stack summaries, (%v, prefix: %v, tags: %v) - %v\n",
 			len(summaries),
 			time.Now().Sub(begin).Round(time.Millisecond),
 			m.options.Prefix,
+			formatTags(m.options.OwnerTags),
 			err,
 		)
 	}(time.Now())
 
+	if len(m.options.OwnerTags) > 0 {
+		stacks, err := m.describeStacks(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list stacks: %w", err)
+		}
+		for _, s := range stacks {
+			if m.isOwned(s) {
+				summaries = append(summaries, makeSummary(s))
+			}
+		}
+		return summaries, nil
+	}
+
 	var token *string
 	for {
 		req := m.api.ListStacksRequest(&cloudformation.ListStacksInput{NextToken: token})
@@ -244,8 +437,169 @@ func (m *Manager) List(ctx context.Context) (summaries []cloudformation.StackSum
 	return summaries, nil
 }
 
+// Plan creates and describes a change set for the insert or update provided.  The
+// resulting plan is printed and passed to the plan handler, if any, but not executed.
+func (m *Manager) Plan(ctx context.Context, change Change) (plan Plan, err error) {
+	stack := change.Stack
+	plan = Plan{
+		Operation:     change.Operation,
+		StackName:     stack.Name,
+		ChangeSetName: makeChangeSetName(),
+	}
+
+	params, err := m.parameters(ctx, stack)
+	if err != nil {
+		return Plan{}, fmt.Errorf("unable to plan stack, %v: %w", stack.Name, err)
+	}
+
+	body, url, err := m.templateSource(ctx, stack)
+	if err != nil {
+		return Plan{}, fmt.Errorf("unable to plan stack, %v: %w", stack.Name, err)
+	}
+
+	changeSetType := cloudformation.ChangeSetTypeUpdate
+	switch change.Operation {
+	case Insert:
+		changeSetType = cloudformation.ChangeSetTypeCreate
+	case Import:
+		changeSetType = cloudformation.ChangeSetTypeImport
+	}
+
+	capabilities, err := stackCapabilities(stack)
+	if err != nil {
+		return Plan{}, fmt.Errorf("unable to plan stack, %v: %w", stack.Name, err)
+	}
+
+	input := cloudformation.CreateChangeSetInput{
+		Capabilities:     capabilities,
+		ChangeSetName:    aws.String(plan.ChangeSetName),
+		ChangeSetType:    changeSetType,
+		NotificationARNs: stack.NotificationARNs,
+		Parameters:       params,
+		StackName:        aws.String(stack.Name),
+		Tags:             mergeTags(m.options.Tags, stack.Tags),
+		TemplateBody:     body,
+		TemplateURL:      url,
+	}
+	if change.Operation == Import {
+		input.ResourcesToImport = stack.ResourcesToImport
+	}
+	if _, err := m.api.CreateChangeSetRequest(&input).Send(ctx); err != nil {
+		return Plan{}, fmt.Errorf("unable to create change set for stack, %v: %w", stack.Name, err)
+	}
+
+	describeInput := cloudformation.DescribeChangeSetInput{
+		ChangeSetName: aws.String(plan.ChangeSetName),
+		StackName:     aws.String(stack.Name),
+	}
+	waitErr := m.api.WaitUntilChangeSetCreateComplete(ctx, &describeInput,
+		aws.WithWaiterDelay(aws.ConstantWaiterDelay(changeSetDelay)),
+	)
+
+	for token := (*string)(nil); ; {
+		describeInput.NextToken = token
+		resp, err := m.api.DescribeChangeSetRequest(&describeInput).Send(ctx)
+		if err != nil {
+			m.abandon(plan)
+			return Plan{}, fmt.Errorf("unable to describe change set for stack, %v: %w", stack.Name, err)
+		}
+
+		if resp.Status == cloudformation.ChangeSetStatusFailed {
+			reason := aws.StringValue(resp.StatusReason)
+			if !isNoChanges(reason) {
+				m.abandon(plan)
+				return Plan{}, fmt.Errorf("change set failed for stack, %v: %v", stack.Name, reason)
+			}
+			if err := m.discard(ctx, plan); err != nil {
+				return Plan{}, err
+			}
+			plan.ChangeSetName = ""
+			plan.Changes = nil
+			break
+		}
+		if waitErr != nil {
+			m.abandon(plan)
+			return Plan{}, fmt.Errorf("failed while waiting for change set for stack, %v: %w", stack.Name, waitErr)
+		}
+
+		plan.Changes = append(plan.Changes, makeResourceChanges(resp.Changes)...)
+
+		token = resp.NextToken
+		if token == nil {
+			break
+		}
+	}
+
+	m.report(plan)
+
+	return plan, nil
+}
+
+// Preview calculates the plan for each change without modifying any stack.  Change
+// sets created to describe updates are deleted once they have been described.
+func (m *Manager) Preview(ctx context.Context, changes ...Change) (plans []Plan, err error) {
+	defer func(begin time.Time) {
+		log.Printf("previewed %v cloudformation changes (%v) - %v\n",
+			len(changes),
+			time.Now().Sub(begin).Round(time.Millisecond),
+			err,
+		)
+	}(time.Now())
+
+	if err := m.Validate(ctx, upsertStacks(changes)...); err != nil {
+		return nil, fmt.Errorf("failed to preview changes: %w", err)
+	}
+
+	// plans use the current outputs of deployed stacks as the parameters of their dependents
+	for _, change := range changes {
+		if change.Operation == Update && !needsCreate(change.Status) {
+			if err := m.collectOutputs(ctx, change.Stack); err != nil {
+				return nil, fmt.Errorf("unable to preview changes: %w", err)
+			}
+		}
+	}
+
+	for _, change := range changes {
+		if len(change.Stack.ResourcesToImport) > 0 && change.Operation != Delete {
+			log.Printf("stack, %v, imports any of these resources not yet imported: %v\n", change.Stack.Name, formatImports(change.Stack.ResourcesToImport))
+		}
+
+		var plan Plan
+		switch change.Operation {
+		case Insert:
+			plan, err = m.previewInsert(change.Stack)
+		case Update:
+			if needsCreate(change.Status) {
+				log.Printf("stack, %v, is %v and will be created again\n", change.Stack.Name, change.Status)
+				plan, err = m.previewInsert(change.Stack)
+				break
+			}
+			plan, err = m.Plan(ctx, change)
+			if err == nil {
+				err = m.discard(ctx, plan)
+			}
+		case Delete:
+			if m.isRetained(change.Stack.Name) {
+				log.Printf("retaining stack, %v.  stack is no longer backed by a template, but will not be deleted\n", change.Stack.Name)
+				continue
+			}
+			plan, err = m.previewDelete(ctx, change.Stack.Name)
+			if err == nil {
+				err = m.warnDelete(ctx, plan)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to preview changes: %w", err)
+		}
+
+		plans = append(plans, plan)
+	}
+
+	return plans, nil
+}
+
 func (m *Manager) Update(ctx context.Context, stack Stack) (err error) {
-	ctx, cancel := context.WithCancel(ctx)
+	ctx, cancel := withTimeout(ctx, m.timeout(stack))
 	defer cancel()
 
 	defer func(begin time.Time) {
@@ -255,59 +609,74 @@ func (m *Manager) Update(ctx context.Context, stack Stack) (err error) {
 			err,
 		)
 	}(time.Now())
-	defer func() {
-		if errors.Is(err, context.Canceled) {
-			err = nil
-		}
-	}()
-
-	params, err := getParameters(stack.TemplateBody, m.options.Parameters)
-	if err != nil {
-		return fmt.Errorf("failed to update stack, %v: %w", stack.Name, err)
-	}
 
 	if m.options.DryRun {
 		log.Printf("dry run.  update not applied for stack, %v - %v\n", stack.Name, err)
 		return nil
 	}
 
-	input := cloudformation.UpdateStackInput{
-		Capabilities: []cloudformation.Capability{
-			cloudformation.CapabilityCapabilityNamedIam,
-		},
-		Parameters:   params,
-		StackName:    aws.String(stack.Name),
-		Tags:         append(m.options.Tags[0:len(m.options.Tags):len(m.options.Tags)], stack.Tags...),
-		TemplateBody: aws.String(stack.TemplateBody),
-	}
-	req := m.api.UpdateStackRequest(&input)
-	_, err = req.Send(ctx)
+	plan, err := m.Plan(ctx, Change{Operation: Update, Stack: stack})
 	if err != nil {
-		var ae awserr.Error
-		if ok := errors.As(err, &ae); ok && ae.Code() == errValidationError {
-			if strings.Contains(ae.Message(), "No updates are to be performed.") {
-				log.Printf("skipping update: no updates required\n")
-				return nil
-			}
-		}
-		return fmt.Errorf("unable to update stack, %v: %w", stack.Name, err)
+		return fmt.Errorf("failed to update stack, %v: %w", stack.Name, err)
 	}
 
-	go func() {
-		defer cancel()
-		observeEvents(ctx, m.api, stack.Name)
-	}()
+	// change sets that change only outputs or exports list no resource changes, but must
+	// still be executed; Plan clears the change set name when there is nothing to do
+	if plan.ChangeSetName == "" {
+		log.Printf("skipping update: no updates required\n")
+	} else if err := m.Execute(ctx, plan); err != nil {
+		return fmt.Errorf("failed to update stack, %v: %w", stack.Name, err)
+	}
 
-	describeInput := cloudformation.DescribeStacksInput{
-		StackName: aws.String(stack.Name),
+	if err := m.configure(ctx, stack); err != nil {
+		return fmt.Errorf("failed to update stack, %v: %w", stack.Name, err)
 	}
-	if err := m.api.WaitUntilStackUpdateComplete(ctx, &describeInput); err != nil {
-		return fmt.Errorf("failed while waiting for update to finish for stack, %v: %w", stack.Name, err)
+
+	return nil
+}
+
+// configure applies the stack settings that cannot be expressed in a change set
+func (m *Manager) configure(ctx context.Context, stack Stack) error {
+	if stack.TerminationProtection != nil {
+		input := cloudformation.UpdateTerminationProtectionInput{
+			EnableTerminationProtection: stack.TerminationProtection,
+			StackName:                   aws.String(stack.Name),
+		}
+		if _, err := m.api.UpdateTerminationProtectionRequest(&input).Send(ctx); err != nil {
+			return fmt.Errorf("unable to update termination protection for stack, %v: %w", stack.Name, err)
+		}
+	}
+
+	if stack.StackPolicy != "" {
+		input := cloudformation.SetStackPolicyInput{
+			StackName:       aws.String(stack.Name),
+			StackPolicyBody: aws.String(stack.StackPolicy),
+		}
+		if _, err := m.api.SetStackPolicyRequest(&input).Send(ctx); err != nil {
+			return fmt.Errorf("unable to set stack policy for stack, %v: %w", stack.Name, err)
+		}
 	}
 
 	return nil
 }
 
+// timeout returns the time the create, update, or delete of the stack may take; the
+// timeout of the stack if set, otherwise the default timeout
+func (m *Manager) timeout(stack Stack) time.Duration {
+	if stack.Timeout > 0 {
+		return stack.Timeout
+	}
+	return m.options.Timeout
+}
+
+// withTimeout returns a context bounded by the timeout, if any
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
 func (m *Manager) Upsert(ctx context.Context, stack Stack) error {
 	input := cloudformation.GetTemplateInput{
 		StackName: aws.String(stack.Name),
@@ -324,12 +693,12 @@ func (m *Manager) Upsert(ctx context.Context, stack Stack) error {
 		return fmt.Errorf("unable to upsert stack, %v: %w", stack.Name, err)
 	}
 
-	got, err := gf.ParseYAML([]byte(*resp.TemplateBody))
+	got, err := parseGoformation(*resp.TemplateBody)
 	if err != nil {
 		return fmt.Errorf("unable to upsert stack: unable to parse current template, %v: %w", stack.Name, err)
 	}
 
-	want, err := gf.ParseYAML([]byte(stack.TemplateBody))
+	want, err := parseGoformation(stack.TemplateBody)
 	if err != nil {
 		return fmt.Errorf("unable to upsert stack: unable to parse new template, %v: %w", stack.Name, err)
 	}
@@ -341,6 +710,250 @@ func (m *Manager) Upsert(ctx context.Context, stack Stack) error {
 	return nil
 }
 
+// previewInsert describes the stack that would be created without calling cloudformation
+func (m *Manager) previewInsert(stack Stack) (Plan, error) {
+	if _, err := getParameters(stack.TemplateBody, m.options.Parameters); err != nil {
+		return Plan{}, fmt.Errorf("unable to preview stack, %v: %w", stack.Name, err)
+	}
+
+	plan, err := insertPlan(stack)
+	if err != nil {
+		return Plan{}, fmt.Errorf("unable to preview stack, %v: %w", stack.Name, err)
+	}
+
+	m.report(plan)
+
+	return plan, nil
+}
+
+// insertPlan lists the resources of the template as the changes of creating the stack
+func insertPlan(stack Stack) (Plan, error) {
+	content, err := parseTemplate(stack.TemplateBody)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	if _, ok := content["Transform"]; ok {
+		log.Printf("stack, %v, uses a transform.  resources are shown before the transform is applied\n", stack.Name)
+	}
+
+	resources, _ := content["Resources"].(map[string]interface{})
+	var ids []string
+	for id := range resources {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	plan := Plan{
+		Operation: Insert,
+		StackName: stack.Name,
+	}
+	for _, id := range ids {
+		plan.Changes = append(plan.Changes, ResourceChange{
+			Action:       cloudformation.ChangeActionAdd,
+			LogicalID:    id,
+			ResourceType: resourceType(resources[id]),
+		})
+	}
+
+	return plan, nil
+}
+
+// previewDelete describes the resources that would be removed along with the stack
+func (m *Manager) previewDelete(ctx context.Context, stackName string) (Plan, error) {
+	plan := Plan{
+		Operation: Delete,
+		StackName: stackName,
+	}
+
+	var token *string
+	for {
+		input := cloudformation.ListStackResourcesInput{
+			NextToken: token,
+			StackName: aws.String(stackName),
+		}
+		resp, err := m.api.ListStackResourcesRequest(&input).Send(ctx)
+		if err != nil {
+			return Plan{}, fmt.Errorf("unable to list resources for stack, %v: %w", stackName, err)
+		}
+
+		for _, r := range resp.StackResourceSummaries {
+			plan.Changes = append(plan.Changes, ResourceChange{
+				Action:       cloudformation.ChangeActionRemove,
+				LogicalID:    aws.StringValue(r.LogicalResourceId),
+				PhysicalID:   aws.StringValue(r.PhysicalResourceId),
+				ResourceType: aws.StringValue(r.ResourceType),
+			})
+		}
+
+		token = resp.NextToken
+		if token == nil {
+			break
+		}
+	}
+
+	m.report(plan)
+
+	return plan, nil
+}
+
+// warnDelete logs the reasons, if any, Apply would refuse the delete described by the plan
+func (m *Manager) warnDelete(ctx context.Context, plan Plan) error {
+	if !m.options.AllowDelete {
+		log.Printf("warning: delete of stack, %v, will be refused unless deletes are allowed\n", plan.StackName)
+	}
+
+	reasons, err := m.deleteRisks(ctx, plan)
+	if err != nil {
+		return err
+	}
+	if !m.options.ForceDelete {
+		for _, reason := range reasons {
+			log.Printf("warning: %v.  delete will be refused unless forced\n", reason)
+		}
+	}
+
+	return nil
+}
+
+// report prints the plan and hands it to the plan handler, if one was provided
+func (m *Manager) report(plan Plan) {
+	m.console.Plan(plan)
+	if fn := m.options.PlanHandler; fn != nil {
+		fn(plan)
+	}
+}
+
+// linkDeletes assigns dependencies between the stacks being deleted using the exports
+// of the deployed stacks and reorders the changes accordingly
+func (m *Manager) linkDeletes(ctx context.Context, changes []Change) ([]Change, error) {
+	deletes := map[string]int{}
+	for i, change := range changes {
+		if change.Operation == Delete {
+			deletes[change.Stack.Name] = i
+		}
+	}
+	if len(deletes) < 2 {
+		return changes, nil
+	}
+
+	for _, change := range changes {
+		if change.Operation != Delete {
+			continue
+		}
+
+		exports, err := m.stackExports(ctx, change.Stack.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, export := range exports {
+			importers, err := m.listImports(ctx, export)
+			if err != nil {
+				return nil, err
+			}
+			for _, importer := range importers {
+				if i, ok := deletes[importer]; ok && importer != change.Stack.Name {
+					changes[i].Stack.DependsOn = appendUnique(changes[i].Stack.DependsOn, change.Stack.Name)
+				}
+			}
+		}
+	}
+
+	return sortChanges(changes)
+}
+
+// stackExports returns the names of the exports declared by the deployed stack
+func (m *Manager) stackExports(ctx context.Context, stackName string) ([]string, error) {
+	input := cloudformation.DescribeStacksInput{
+		StackName: aws.String(stackName),
+	}
+	resp, err := m.api.DescribeStacksRequest(&input).Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe stack, %v: %w", stackName, err)
+	}
+
+	var exports []string
+	for _, s := range resp.Stacks {
+		for _, output := range s.Outputs {
+			if name := aws.StringValue(output.ExportName); name != "" {
+				exports = append(exports, name)
+			}
+		}
+	}
+	return exports, nil
+}
+
+// listImports returns the names of the stacks that import the export provided
+func (m *Manager) listImports(ctx context.Context, exportName string) ([]string, error) {
+	var imports []string
+	var token *string
+	for {
+		input := cloudformation.ListImportsInput{
+			ExportName: aws.String(exportName),
+			NextToken:  token,
+		}
+		resp, err := m.api.ListImportsRequest(&input).Send(ctx)
+		if err != nil {
+			var ae awserr.Error
+			if ok := errors.As(err, &ae); ok && ae.Code() == errValidationError {
+				if strings.Contains(ae.Message(), "is not imported by any stack") {
+					return nil, nil
+				}
+			}
+			return nil, fmt.Errorf("unable to list imports of export, %v: %w", exportName, err)
+		}
+		imports = append(imports, resp.Imports...)
+
+		token = resp.NextToken
+		if token == nil {
+			break
+		}
+	}
+	return imports, nil
+}
+
+// discard deletes the change set associated with the plan without executing it
+func (m *Manager) discard(ctx context.Context, plan Plan) error {
+	if plan.ChangeSetName == "" {
+		return nil
+	}
+
+	input := cloudformation.DeleteChangeSetInput{
+		ChangeSetName: aws.String(plan.ChangeSetName),
+		StackName:     aws.String(plan.StackName),
+	}
+	if _, err := m.api.DeleteChangeSetRequest(&input).Send(ctx); err != nil {
+		return fmt.Errorf("unable to delete change set, %v, for stack, %v: %w", plan.ChangeSetName, plan.StackName, err)
+	}
+	return nil
+}
+
+// abandon cleans up after a plan whose change set failed, could not be waited on, or could
+// not be executed.  The change set is deleted and, for inserts, so is the stack created to
+// hold it, which would otherwise be left REVIEW_IN_PROGRESS.  A context of its own is used
+// so plans abandoned because their context ended are cleaned up too.
+func (m *Manager) abandon(plan Plan) {
+	ctx, cancel := context.WithTimeout(context.Background(), expireTimeout)
+	defer cancel()
+
+	if err := m.discard(ctx, plan); err != nil {
+		log.Println(err)
+	}
+
+	if plan.Operation != Insert {
+		return
+	}
+
+	log.Printf("deleting stack, %v, created for its failed change set\n", plan.StackName)
+	input := cloudformation.DeleteStackInput{
+		StackName: aws.String(plan.StackName),
+	}
+	if _, err := m.api.DeleteStackRequest(&input).Send(ctx); err != nil {
+		log.Printf("unable to delete stack, %v: %v\n", plan.StackName, err)
+	}
+}
+
 func hasPrefix(got string, prefixes ...string) bool {
 	if len(prefixes) == 0 {
 		return true
@@ -355,20 +968,18 @@ func hasPrefix(got string, prefixes ...string) bool {
 	return false
 }
 
-// getParameters introspects the yaml template body provided and selects parameters
-// from the list provided
+// getParameters introspects the yaml or json template body provided and selects
+// parameters from the list provided
 func getParameters(body string, all map[string]string) ([]cloudformation.Parameter, error) {
-	var content struct {
-		Parameters map[string]struct {
-			Type string `yaml:"Type"`
-		} `yaml:"Parameters"`
-	}
-	if err := yaml.Unmarshal([]byte(body), &content); err != nil {
-		return nil, fmt.Errorf("failed to parse cloudformation template: %w", err)
+	content, err := parseTemplate(body)
+	if err != nil {
+		return nil, err
 	}
 
+	parameters, _ := content["Parameters"].(map[string]interface{})
+
 	var params []cloudformation.Parameter
-	for name := range content.Parameters {
+	for name := range parameters {
 		v, ok := all[name]
 		if ok {
 			params = append(params, cloudformation.Parameter{
@@ -380,3 +991,93 @@ func getParameters(body string, all map[string]string) ([]cloudformation.Paramet
 
 	return params, nil
 }
+
+// parameters returns the parameters the stack template declares, resolved and ready to
+// pass to cloudformation.  The values are logged with NoEcho and sensitive values redacted.
+func (m *Manager) parameters(ctx context.Context, stack Stack) ([]cloudformation.Parameter, error) {
+	params, redact, err := m.resolveParameters(ctx, stack)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("parameters for stack, %v: %v\n", stack.Name, formatParameters(params, redact))
+
+	return params, nil
+}
+
+// resolveParameters returns the resolved values of the parameters the stack template
+// declares, sorted by name, along with the names of the parameters whose values must
+// not be logged
+func (m *Manager) resolveParameters(ctx context.Context, stack Stack) (params []cloudformation.Parameter, redact []string, err error) {
+	params, err = getParameters(stack.TemplateBody, mergeParameters(m.options.Parameters, m.Outputs(), stack.Parameters))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	redact, err = noEchoParameters(stack.TemplateBody)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if fn := m.options.ResolveParameter; fn != nil {
+		for i, p := range params {
+			value, sensitive, err := fn(ctx, aws.StringValue(p.ParameterValue))
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to resolve parameter, %v: %w", aws.StringValue(p.ParameterKey), err)
+			}
+			params[i].ParameterValue = aws.String(value)
+			if sensitive {
+				redact = append(redact, aws.StringValue(p.ParameterKey))
+			}
+		}
+	}
+
+	sort.Slice(params, func(i, j int) bool {
+		return aws.StringValue(params[i].ParameterKey) < aws.StringValue(params[j].ParameterKey)
+	})
+
+	return params, redact, nil
+}
+
+// noEchoParameters returns the names of the parameters the template declares as NoEcho
+func noEchoParameters(body string) ([]string, error) {
+	content, err := parseTemplate(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	parameters, _ := content["Parameters"].(map[string]interface{})
+	for name, v := range parameters {
+		p, _ := v.(map[string]interface{})
+		if fmt.Sprint(p["NoEcho"]) == "true" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// formatParameters renders parameters as key=value pairs for logging.  Values of the
+// redacted parameters are masked.
+func formatParameters(params []cloudformation.Parameter, redact []string) string {
+	var pairs []string
+	for _, p := range params {
+		key, value := aws.StringValue(p.ParameterKey), aws.StringValue(p.ParameterValue)
+		if containsString(redact, key) {
+			value = "****"
+		}
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// isNoChanges returns true if the change set status reason indicates the
+// template and parameters match the current stack
+func isNoChanges(reason string) bool {
+	return strings.Contains(reason, "didn't contain changes") ||
+		strings.Contains(reason, "No updates are to be performed")
+}
+
+func makeChangeSetName() string {
+	return "fairy-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
@@ -1,7 +1,14 @@
 package stack
 
 import (
+	"bytes"
+	"context"
 	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -40,4 +47,71 @@ func Test_getParameters(t *testing.T) {
 			t.Fatalf("got %v; want %v", got, want)
 		}
 	})
+
+	t.Run("json", func(t *testing.T) {
+		data, err := ioutil.ReadFile("testdata/formats/network.json")
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		parameters, err := getParameters(string(data), map[string]string{"Env": "local"})
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := len(parameters), 1; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
+
+func TestManager_parameters(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/parameters-noecho.template")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	resolver := func(ctx context.Context, value string) (string, bool, error) {
+		if value == "secret:api-key" {
+			return "abc123", true, nil
+		}
+		return value, false, nil
+	}
+	manager := New(nil,
+		WithParameters(map[string]string{"Domain": "example.com", "Password": "hunter2", "Other": "ignored"}),
+		WithParameterResolver(resolver),
+	)
+
+	s := Stack{
+		Name:         "local-example-topic",
+		TemplateBody: string(data),
+		Parameters:   map[string]string{"ApiKey": "secret:api-key"},
+	}
+	params, redact, err := manager.resolveParameters(context.Background(), s)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	sort.Strings(redact)
+	if got, want := redact, []string{"ApiKey", "Password"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := formatParameters(params, nil), "ApiKey=abc123,Domain=example.com,Password=hunter2"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	if _, err := manager.parameters(context.Background(), s); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := buf.String(), "ApiKey=****,Domain=example.com,Password=****"; !strings.Contains(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	for _, secret := range []string{"abc123", "hunter2"} {
+		if got := buf.String(); strings.Contains(got, secret) {
+			t.Fatalf("got %v; want no %v", got, secret)
+		}
+	}
 }
@@ -17,14 +17,12 @@ package stack
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/awserr"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation/cloudformationiface"
-	"github.com/fatih/color"
 )
 
 func isErrShown(err error) bool {
@@ -40,89 +38,103 @@ func isErrShown(err error) bool {
 	return true
 }
 
-func observeEvents(ctx context.Context, api cloudformationiface.ClientAPI, stackName string) {
-	ticker := time.NewTicker(6 * time.Second)
-	defer ticker.Stop()
-
-	now := time.Now().Add(-12 * time.Second)
-	seen := map[string]struct{}{} // keep track of events we've seen
+// observe passes the events of the stack to the event handlers until the stack operation
+// completes.  Without handlers, events are written to the console.
+func (m *Manager) observe(ctx context.Context, stackName string) {
+	handlers := m.options.EventHandlers
+	if len(handlers) == 0 {
+		handlers = []EventHandler{m.console.Event}
+	}
 
-outer:
-	for iter := 0; true; iter++ {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
+	for event := range streamEvents(ctx, m.api, stackName) {
+		for _, h := range handlers {
+			h(event)
 		}
+	}
+}
 
-		input := cloudformation.DescribeStackEventsInput{
-			StackName: aws.String(stackName),
-		}
+// streamEvents polls the events of the stack and sends each new event, oldest first, to
+// the channel returned.  The channel is closed once the stack operation completes or the
+// context is done.
+func streamEvents(ctx context.Context, api cloudformationiface.ClientAPI, stackName string) <-chan Event {
+	ch := make(chan Event)
 
-		var token *string
-		for {
-			input.NextToken = token
+	go func() {
+		defer close(ch)
 
-			req := api.DescribeStackEventsRequest(&input)
-			resp, err := req.Send(ctx)
-			if err != nil {
-				if isErrShown(err) {
-					fmt.Printf("describe stack events failed for stack, %v - %v\n", stackName, err)
-				}
+		ticker := time.NewTicker(6 * time.Second)
+		defer ticker.Stop()
 
-				select {
-				case <-ctx.Done():
-					return
-				case <-time.After(12 * time.Second):
-					continue outer
-				}
+		now := time.Now().Add(-12 * time.Second)
+		seen := map[string]struct{}{} // keep track of events we've seen
+
+	outer:
+		for iter := 0; true; iter++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
 			}
 
-			var events []cloudformation.StackEvent
-			for _, event := range resp.StackEvents {
-				if event.Timestamp.Before(now) {
-					break
-				}
+			input := cloudformation.DescribeStackEventsInput{
+				StackName: aws.String(stackName),
+			}
 
-				if _, ok := seen[*event.EventId]; ok {
-					continue
+			var token *string
+			for {
+				input.NextToken = token
+
+				req := api.DescribeStackEventsRequest(&input)
+				resp, err := req.Send(ctx)
+				if err != nil {
+					if isErrShown(err) {
+						fmt.Printf("describe stack events failed for stack, %v - %v\n", stackName, err)
+					}
+
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(12 * time.Second):
+						continue outer
+					}
 				}
-				seen[*event.EventId] = struct{}{}
 
-				events = append(events, event)
-			}
+				var events []cloudformation.StackEvent
+				for _, event := range resp.StackEvents {
+					if event.Timestamp.Before(now) {
+						break
+					}
 
-			for i := len(events) - 1; i >= 0; i-- {
-				event := events[i]
-				text := fmt.Sprintf("%s %-25s %-35s %-35s %s\n",
-					event.Timestamp.In(time.Local).Format("2006/01/02 15:04:05"),
-					aws.StringValue(event.LogicalResourceId),
-					aws.StringValue(event.ResourceType),
-					event.ResourceStatus,
-					aws.StringValue(event.ResourceStatusReason),
-				)
-
-				if status := string(event.ResourceStatus); strings.Contains(status, "FAILED") || strings.Contains(status, "DELETE") {
-					color.Red(text)
-				} else if strings.Contains(status, "UPDATE") {
-					color.Yellow(text)
-				} else if strings.Contains(status, "CREATE") {
-					color.Green(text)
-				} else {
-					color.Blue(text)
+					if _, ok := seen[*event.EventId]; ok {
+						continue
+					}
+					seen[*event.EventId] = struct{}{}
+
+					events = append(events, event)
 				}
 
-				if iter > 0 && isComplete(stackName, event) {
-					return
+				for i := len(events) - 1; i >= 0; i-- {
+					event := events[i]
+					select {
+					case <-ctx.Done():
+						return
+					case ch <- makeEvent(event):
+					}
+
+					if iter > 0 && isComplete(stackName, event) {
+						return
+					}
 				}
-			}
 
-			token = resp.NextToken
-			if token == nil {
-				break
+				token = resp.NextToken
+				if token == nil {
+					break
+				}
 			}
 		}
-	}
+	}()
+
+	return ch
 }
 
 var completeResourceStatuses = []cloudformation.ResourceStatus{
@@ -15,31 +15,122 @@
 package stack
 
 import (
+	"context"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/s3/s3iface"
 )
 
 type Options struct {
-	DryRun     bool
-	Parameters map[string]string
-	FormatName func(string) string
-	Prefix     string
-	Tags       []cloudformation.Tag
+	AllowDelete bool
+	Concurrency int
+	DryRun      bool
+	ForceDelete bool
+	Parameters  map[string]string
+	FormatName  func(string) string
+	PlanHandler func(Plan)
+	OwnerTags   []cloudformation.Tag
+	Prefix      string
+	Project     string
+	Retain      []string
+	Tags        []cloudformation.Tag
+
+	// BootstrapExports holds the bootstrap exports available to rendered templates
+	BootstrapExports map[string]string
+
+	// TemplateBucket holds the s3 location templates are uploaded to when passed by url
+	TemplateBucket *templateBucket
+	// UploadTemplates passes all templates by url rather than just oversized ones
+	UploadTemplates bool
+
+	// Timeout bounds the create, update, or delete of stacks that set no timeout of their
+	// own; zero means no limit
+	Timeout time.Duration
+	// TimeoutAction determines whether creates and updates that exceed their timeout are cancelled
+	TimeoutAction TimeoutAction
+
+	// Console receives the plans, and the events when no handlers are provided, of each
+	// stack.  Defaults to a console on stdout.
+	Console *Console
+
+	// EventHandlers receive the events of each stack as it changes
+	EventHandlers []EventHandler
+
+	// ResolveParameter, if set, resolves each parameter value passed to cloudformation
+	ResolveParameter ParameterResolver
+
+	// ResourcesToSkip holds, by stack name, the logical ids of resources to skip when
+	// continuing a failed update rollback
+	ResourcesToSkip map[string][]string
 }
 
 type Option func(o *Options)
 
+// ParameterResolver returns the value to pass to cloudformation for the parameter value
+// provided.  sensitive indicates the resolved value must not be logged.
+type ParameterResolver func(ctx context.Context, value string) (resolved string, sensitive bool, err error)
+
 func defaultNameFormatter(s string) string {
 	return s
 }
 
+// WithAllowDelete permits Apply to delete stacks that are no longer backed by a template
+func WithAllowDelete(allow bool) Option {
+	return func(o *Options) {
+		o.AllowDelete = allow
+	}
+}
+
+// WithBootstrapExports makes the bootstrap exports available to templates rendered with
+// text/template
+func WithBootstrapExports(exports map[string]string) Option {
+	return func(o *Options) {
+		o.BootstrapExports = exports
+	}
+}
+
+// WithConcurrency sets the maximum number of stacks Apply modifies at once.  Stacks
+// are only applied concurrently when neither depends on the other.
+func WithConcurrency(n int) Option {
+	return func(o *Options) {
+		o.Concurrency = n
+	}
+}
+
 func WithDryRun(dryRun bool) Option {
 	return func(o *Options) {
 		o.DryRun = dryRun
 	}
 }
 
+// WithConsole sets the console that plans, and events when no handlers are provided, are
+// written to.  Managers that share a console do not interleave their output.
+func WithConsole(console *Console) Option {
+	return func(o *Options) {
+		o.Console = console
+	}
+}
+
+// WithEventHandler adds a handler that receives the events of each stack as it changes.
+// Events are written to the console only when no handlers are provided.
+func WithEventHandler(fn EventHandler) Option {
+	return func(o *Options) {
+		if fn != nil {
+			o.EventHandlers = append(o.EventHandlers, fn)
+		}
+	}
+}
+
+// WithForceDelete permits Apply to delete stacks that have termination protection enabled
+// or contain stateful resources such as tables, databases, and buckets
+func WithForceDelete(force bool) Option {
+	return func(o *Options) {
+		o.ForceDelete = force
+	}
+}
+
 func WithNameFormatter(fn func(string) string) Option {
 	return func(o *Options) {
 		if fn == nil {
@@ -50,6 +141,15 @@ func WithNameFormatter(fn func(string) string) Option {
 	}
 }
 
+// WithOwnerTags stamps the tags onto every stack created or updated and restricts List
+// to stacks carrying all of them
+func WithOwnerTags(tags ...cloudformation.Tag) Option {
+	return func(o *Options) {
+		o.OwnerTags = append(o.OwnerTags, tags...)
+		o.Tags = append(o.Tags, tags...)
+	}
+}
+
 func WithParameters(exports map[string]string) Option {
 	return func(o *Options) {
 		for k, v := range exports {
@@ -61,6 +161,21 @@ func WithParameters(exports map[string]string) Option {
 	}
 }
 
+// WithParameterResolver resolves parameter values, e.g. references to secrets, at the time
+// each change set is created
+func WithParameterResolver(fn ParameterResolver) Option {
+	return func(o *Options) {
+		o.ResolveParameter = fn
+	}
+}
+
+// WithPlanHandler registers a callback that receives each plan prior to execution
+func WithPlanHandler(fn func(Plan)) Option {
+	return func(o *Options) {
+		o.PlanHandler = fn
+	}
+}
+
 func WithPrefix(prefix string) Option {
 	prefix = strings.TrimRight(prefix, "-") + "-"
 
@@ -69,15 +184,75 @@ func WithPrefix(prefix string) Option {
 	}
 }
 
+// WithProject sets the project name available to templates rendered with text/template
+func WithProject(project string) Option {
+	return func(o *Options) {
+		o.Project = project
+	}
+}
+
+// WithResourcesToSkip identifies resources of the stack that should be skipped when a
+// failed update rollback is continued
+func WithResourcesToSkip(stackName string, logicalIDs ...string) Option {
+	return func(o *Options) {
+		if o.ResourcesToSkip == nil {
+			o.ResourcesToSkip = map[string][]string{}
+		}
+		o.ResourcesToSkip[stackName] = append(o.ResourcesToSkip[stackName], logicalIDs...)
+	}
+}
+
+// WithRetain identifies stacks, by stack or template name, that must never be deleted
+func WithRetain(names ...string) Option {
+	return func(o *Options) {
+		o.Retain = append(o.Retain, names...)
+	}
+}
+
 func WithTags(tags ...cloudformation.Tag) Option {
 	return func(o *Options) {
 		o.Tags = append(o.Tags, tags...)
 	}
 }
 
+// WithTemplateBucket identifies the s3 bucket and key prefix templates are uploaded to
+// when they are too large to be passed inline.  The region is used to construct the
+// template url and must match the region of the stacks.
+func WithTemplateBucket(api s3iface.ClientAPI, region, bucket, prefix string) Option {
+	return func(o *Options) {
+		o.TemplateBucket = &templateBucket{
+			api:    api,
+			region: region,
+			bucket: bucket,
+			prefix: prefix,
+		}
+	}
+}
+
+// WithTimeout bounds the time stack operations may take.  Timeouts set by the stack
+// config take precedence.  Unless action is TimeoutContinue, stacks are created with
+// TimeoutInMinutes so cloudformation rolls back creates that exceed it.  Operations that
+// exceed their timeout are handled according to action; unless action is TimeoutContinue,
+// updates are cancelled and stacks being created are deleted.  Deletes are left running.
+func WithTimeout(timeout time.Duration, action TimeoutAction) Option {
+	return func(o *Options) {
+		o.Timeout = timeout
+		o.TimeoutAction = action
+	}
+}
+
+// WithUploadTemplates passes every template by url, not just those exceeding the inline
+// limit.  Requires WithTemplateBucket.
+func WithUploadTemplates(upload bool) Option {
+	return func(o *Options) {
+		o.UploadTemplates = upload
+	}
+}
+
 func buildOptions(opts ...Option) Options {
 	options := Options{
-		FormatName: defaultNameFormatter,
+		Concurrency: 1,
+		FormatName:  defaultNameFormatter,
 	}
 
 	for _, opt := range opts {
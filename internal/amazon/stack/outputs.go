@@ -0,0 +1,200 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// makeNamespace converts the template name into the namespace of its outputs e.g.
+// my-network becomes MyNetwork
+func makeNamespace(name string) string {
+	var namespace string
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+		namespace += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return namespace
+}
+
+// reservedParameters holds the parameters assigned by fairy itself.  Outputs may not be
+// published under these names.
+var reservedParameters = []string{CloudMapNamespaceARN, Env, S3Bucket, S3Prefix, Version}
+
+// outputParameterNames returns the names of the parameters the output of the stack is
+// published as; ${Namespace}${Key}, which a template may declare to receive it, and the
+// name configured by the stack config, if any
+func outputParameterNames(stack Stack, key string) []string {
+	names := []string{
+		stack.Namespace + key,
+	}
+	if name, ok := stack.OutputParameters[key]; ok {
+		names = append(names, name)
+	}
+	return names
+}
+
+// templateKeys returns the names of the parameters and outputs declared by the template
+func templateKeys(body string) (parameters, outputs []string, err error) {
+	content, err := parseTemplate(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if v, ok := content["Parameters"].(map[string]interface{}); ok {
+		for name := range v {
+			parameters = append(parameters, name)
+		}
+	}
+	if v, ok := content["Outputs"].(map[string]interface{}); ok {
+		for name := range v {
+			outputs = append(outputs, name)
+		}
+	}
+
+	sort.Strings(parameters)
+	sort.Strings(outputs)
+
+	return parameters, outputs, nil
+}
+
+// Outputs returns the outputs collected from the stacks applied so far, keyed by the
+// parameter names they are published as
+func (m *Manager) Outputs() map[string]string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return mergeParameters(m.outputs)
+}
+
+// ReadOutputs returns the outputs of the deployed stacks keyed by ${Namespace}${Key} and
+// by any name configured by the stack config.  Stacks that have not been deployed are
+// skipped.
+func (m *Manager) ReadOutputs(ctx context.Context, stacks ...Stack) (map[string]string, error) {
+	outputs := map[string]string{}
+	for _, stack := range stacks {
+		found, err := m.describeOutputs(ctx, stack)
+		if err != nil {
+			var ae awserr.Error
+			if ok := errors.As(err, &ae); ok && ae.Code() == errValidationError {
+				if strings.Contains(ae.Message(), "does not exist") {
+					continue
+				}
+			}
+			return nil, err
+		}
+		for _, output := range found {
+			key, value := aws.StringValue(output.OutputKey), aws.StringValue(output.OutputValue)
+			outputs[stack.Namespace+key] = value
+			if name, ok := stack.OutputParameters[key]; ok {
+				outputs[name] = value
+			}
+		}
+	}
+	return outputs, nil
+}
+
+// collectOutputs reads the outputs of the deployed stack and publishes them as
+// parameters for the stacks that follow
+func (m *Manager) collectOutputs(ctx context.Context, stack Stack) error {
+	outputs, err := m.describeOutputs(ctx, stack)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.outputs == nil {
+		m.outputs = map[string]string{}
+	}
+	for _, output := range outputs {
+		key, value := aws.StringValue(output.OutputKey), aws.StringValue(output.OutputValue)
+		for _, name := range outputParameterNames(stack, key) {
+			m.outputs[name] = value
+		}
+		log.Printf("published output of stack, %v, as parameter, %v\n", stack.Name, strings.Join(outputParameterNames(stack, key), ", "))
+	}
+
+	return nil
+}
+
+// describeOutputs returns the outputs of the deployed stack
+func (m *Manager) describeOutputs(ctx context.Context, stack Stack) ([]cloudformation.Output, error) {
+	input := cloudformation.DescribeStacksInput{
+		StackName: aws.String(stack.Name),
+	}
+	resp, err := m.api.DescribeStacksRequest(&input).Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read outputs of stack, %v: %w", stack.Name, err)
+	}
+
+	var outputs []cloudformation.Output
+	for _, s := range resp.Stacks {
+		outputs = append(outputs, s.Outputs...)
+	}
+	return outputs, nil
+}
+
+// outputConflicts returns a problem for each parameter name an output would be published
+// as that is reserved, is already assigned by parameters, or is published by more than one
+// output.  Such outputs would silently replace the values other stacks receive.
+func outputConflicts(stacks []Stack, parameters map[string]string) []string {
+	var (
+		problems  []string
+		providers = map[string]string{}
+	)
+	for _, s := range stacks {
+		for _, key := range s.OutputKeys {
+			output := s.Name + "." + key
+			for _, name := range outputParameterNames(s, key) {
+				_, assigned := parameters[name]
+				provider, provided := providers[name]
+				switch {
+				case containsString(reservedParameters, name):
+					problems = append(problems, fmt.Sprintf("%v: output, %v, may not be published as reserved parameter, %v", s.Name, key, name))
+				case assigned:
+					problems = append(problems, fmt.Sprintf("%v: output, %v, may not be published as parameter, %v, which is already assigned", s.Name, key, name))
+				case provided && provider != output:
+					problems = append(problems, fmt.Sprintf("%v: output, %v, may not be published as parameter, %v, which is published by output, %v", s.Name, key, name, provider))
+				}
+				providers[name] = output
+			}
+		}
+	}
+	return problems
+}
+
+// providedParameters returns the names of the parameters the outputs of the stacks will
+// provide once they are applied
+func providedParameters(stacks []Stack) map[string]string {
+	providers := map[string]string{}
+	for _, s := range stacks {
+		for _, key := range s.OutputKeys {
+			for _, name := range outputParameterNames(s, key) {
+				providers[name] = s.Name
+			}
+		}
+	}
+	return providers
+}
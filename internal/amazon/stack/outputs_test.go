@@ -0,0 +1,115 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func Test_makeNamespace(t *testing.T) {
+	testCases := map[string]string{
+		"network":        "Network",
+		"my-network":     "MyNetwork",
+		"api_gateway.v2": "ApiGatewayV2",
+		"vpcPeering":     "VpcPeering",
+	}
+
+	for name, want := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := makeNamespace(name); got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadAll_outputs(t *testing.T) {
+	opts := []Option{
+		WithPrefix("local-outputs-"),
+		WithParameters(map[string]string{Env: "local"}),
+	}
+
+	stacks, err := LoadAll("testdata/outputs", opts...)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	want := []string{"local-outputs-network", "local-outputs-service"}
+	if got := stackNames(stacks); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	network, service := stacks[0], stacks[1]
+	if got, want := network.Namespace, "Network"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := network.OutputKeys, []string{"SubnetId", "VpcId"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := outputParameterNames(network, "SubnetId"), []string{"NetworkSubnetId", "PrivateSubnet"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := service.DependsOn, []string{"local-outputs-network"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// parameters provided by the outputs of other stacks are not required up front
+	if err := New(nil, opts...).Validate(context.Background(), stacks...); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := New(nil, opts...).Validate(context.Background(), service); err == nil {
+		t.Fatalf("got nil; want err")
+	}
+}
+
+func Test_outputConflicts(t *testing.T) {
+	s3 := Stack{Name: "app-s3", Namespace: "S3", OutputKeys: []string{"Bucket", "Arn"}}
+	network := Stack{Name: "app-network", Namespace: "Network", OutputKeys: []string{"VpcId"}}
+	vpc := Stack{Name: "app-vpc", Namespace: "Vpc", OutputKeys: []string{"Id"}, OutputParameters: map[string]string{"Id": "NetworkVpcId"}}
+
+	testCases := map[string]struct {
+		Stacks     []Stack
+		Parameters map[string]string
+		Want       int
+	}{
+		"none": {
+			Stacks:     []Stack{network},
+			Parameters: map[string]string{Env: "local"},
+		},
+		"reserved": {
+			Stacks: []Stack{s3},
+			Want:   1,
+		},
+		"assigned": {
+			Stacks:     []Stack{network},
+			Parameters: map[string]string{"NetworkVpcId": "vpc-123"},
+			Want:       1,
+		},
+		"published twice": {
+			Stacks: []Stack{network, vpc},
+			Want:   1,
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			if got := outputConflicts(tc.Stacks, tc.Parameters); len(got) != tc.Want {
+				t.Fatalf("got %v; want %v problems", got, tc.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// Adopt stamps the owner tags onto an existing stack so that it is considered by List.
+// The stack template and parameters are left unchanged.
+func (m *Manager) Adopt(ctx context.Context, s cloudformation.Stack) (err error) {
+	stackName := aws.StringValue(s.StackName)
+	defer func(begin time.Time) {
+		log.Printf("adopted cloudformation stack, %v (%v) - %v\n",
+			stackName,
+			time.Now().Sub(begin).Round(time.Millisecond),
+			err,
+		)
+	}(time.Now())
+
+	if m.options.DryRun {
+		log.Printf("dry run.  adopt not applied for stack, %v\n", stackName)
+		return nil
+	}
+
+	var params []cloudformation.Parameter
+	for _, p := range s.Parameters {
+		params = append(params, cloudformation.Parameter{
+			ParameterKey:     p.ParameterKey,
+			UsePreviousValue: aws.Bool(true),
+		})
+	}
+
+	input := cloudformation.UpdateStackInput{
+		Capabilities:        s.Capabilities,
+		NotificationARNs:    s.NotificationARNs,
+		Parameters:          params,
+		StackName:           s.StackName,
+		Tags:                mergeTags(s.Tags, m.options.OwnerTags),
+		UsePreviousTemplate: aws.Bool(true),
+	}
+	if _, err := m.api.UpdateStackRequest(&input).Send(ctx); err != nil {
+		var ae awserr.Error
+		if ok := errors.As(err, &ae); ok && ae.Code() == errValidationError && isNoChanges(ae.Message()) {
+			return nil
+		}
+		return fmt.Errorf("unable to adopt stack, %v: %w", stackName, err)
+	}
+
+	describeInput := cloudformation.DescribeStacksInput{
+		StackName: s.StackName,
+	}
+	if err := m.api.WaitUntilStackUpdateComplete(ctx, &describeInput); err != nil {
+		return fmt.Errorf("failed while waiting for adopt to finish for stack, %v: %w", stackName, err)
+	}
+
+	return nil
+}
+
+// ListUnowned returns the stacks that match the prefix, but carry none of the owner tag
+// keys.  These are typically stacks created before ownership tags were introduced.
+// Stacks tagged as owned by someone else are not returned.
+func (m *Manager) ListUnowned(ctx context.Context) ([]cloudformation.Stack, error) {
+	stacks, err := m.describeStacks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var unowned []cloudformation.Stack
+	for _, s := range stacks {
+		if hasPrefix(aws.StringValue(s.StackName), m.options.Prefix) && !m.hasOwnerKey(s) {
+			unowned = append(unowned, s)
+		}
+	}
+	return unowned, nil
+}
+
+// describeStacks returns all live stacks within the account and region
+func (m *Manager) describeStacks(ctx context.Context) ([]cloudformation.Stack, error) {
+	var stacks []cloudformation.Stack
+	var token *string
+	for {
+		input := cloudformation.DescribeStacksInput{NextToken: token}
+		resp, err := m.api.DescribeStacksRequest(&input).Send(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe stacks: %w", err)
+		}
+		stacks = append(stacks, resp.Stacks...)
+
+		token = resp.NextToken
+		if token == nil {
+			break
+		}
+	}
+	return stacks, nil
+}
+
+// isOwned returns true if the stack carries every owner tag
+func (m *Manager) isOwned(s cloudformation.Stack) bool {
+	if len(m.options.OwnerTags) == 0 {
+		return false
+	}
+
+outer:
+	for _, want := range m.options.OwnerTags {
+		for _, got := range s.Tags {
+			if aws.StringValue(got.Key) == aws.StringValue(want.Key) && aws.StringValue(got.Value) == aws.StringValue(want.Value) {
+				continue outer
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// hasOwnerKey returns true if the stack carries any of the owner tag keys, regardless of value
+func (m *Manager) hasOwnerKey(s cloudformation.Stack) bool {
+	for _, want := range m.options.OwnerTags {
+		for _, got := range s.Tags {
+			if aws.StringValue(got.Key) == aws.StringValue(want.Key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// makeSummary converts a stack into a summary equivalent to the one returned by ListStacks
+func makeSummary(s cloudformation.Stack) cloudformation.StackSummary {
+	return cloudformation.StackSummary{
+		CreationTime:        s.CreationTime,
+		DeletionTime:        s.DeletionTime,
+		LastUpdatedTime:     s.LastUpdatedTime,
+		ParentId:            s.ParentId,
+		RootId:              s.RootId,
+		StackId:             s.StackId,
+		StackName:           s.StackName,
+		StackStatus:         s.StackStatus,
+		StackStatusReason:   s.StackStatusReason,
+		TemplateDescription: s.Description,
+	}
+}
+
+// mergeTags returns the union of the tag sets provided.  When keys collide, later
+// tags replace earlier ones.
+func mergeTags(sets ...[]cloudformation.Tag) []cloudformation.Tag {
+	var (
+		tags  []cloudformation.Tag
+		index = map[string]int{}
+	)
+	for _, set := range sets {
+		for _, tag := range set {
+			key := aws.StringValue(tag.Key)
+			if i, ok := index[key]; ok {
+				tags[i] = tag
+				continue
+			}
+			index[key] = len(tags)
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// formatTags renders tags as key=value pairs for logging
+func formatTags(tags []cloudformation.Tag) string {
+	var pairs []string
+	for _, tag := range tags {
+		pairs = append(pairs, aws.StringValue(tag.Key)+"="+aws.StringValue(tag.Value))
+	}
+	return strings.Join(pairs, ",")
+}
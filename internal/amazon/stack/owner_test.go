@@ -0,0 +1,71 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+func makeTag(key, value string) cloudformation.Tag {
+	return cloudformation.Tag{Key: aws.String(key), Value: aws.String(value)}
+}
+
+func TestManager_isOwned(t *testing.T) {
+	manager := New(nil, WithOwnerTags(makeTag("fairy:env", "local"), makeTag("fairy:project", "example")))
+
+	testCases := map[string]struct {
+		Tags      []cloudformation.Tag
+		WantOwned bool
+		WantKey   bool
+	}{
+		"untagged": {},
+		"owned": {
+			Tags:      []cloudformation.Tag{makeTag("other", "x"), makeTag("fairy:project", "example"), makeTag("fairy:env", "local")},
+			WantOwned: true,
+			WantKey:   true,
+		},
+		"other project": {
+			Tags:    []cloudformation.Tag{makeTag("fairy:project", "example-api"), makeTag("fairy:env", "local")},
+			WantKey: true,
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			s := cloudformation.Stack{Tags: tc.Tags}
+			if got, want := manager.isOwned(s), tc.WantOwned; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+			if got, want := manager.hasOwnerKey(s), tc.WantKey; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+func Test_mergeTags(t *testing.T) {
+	got := mergeTags(
+		[]cloudformation.Tag{makeTag("a", "1"), makeTag("b", "2")},
+		[]cloudformation.Tag{makeTag("b", "3"), makeTag("c", "4")},
+	)
+	want := []cloudformation.Tag{makeTag("a", "1"), makeTag("b", "3"), makeTag("c", "4")}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,408 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// artifactProperty describes a resource property that may refer to a local path
+type artifactProperty struct {
+	// Name of the property
+	Name string
+	// Zip indicates directories, and files other than zip and jar files, are zipped
+	Zip bool
+	// Bucket and Key, when set, name the fields of the object that replaces the local
+	// path e.g. {"S3Bucket": "...", "S3Key": "..."}.  Otherwise, the local path is
+	// replaced with an s3://bucket/key uri.
+	Bucket, Key string
+	// URL indicates the local path is replaced with the https url of the object
+	URL bool
+	// Template indicates the artifact is a nested template that is itself packaged
+	Template bool
+}
+
+// artifactProperties holds, by resource type, the properties that may refer to local paths
+var artifactProperties = map[string][]artifactProperty{
+	"AWS::ApiGateway::RestApi": {
+		{Name: "BodyS3Location", Bucket: "Bucket", Key: "Key"},
+	},
+	"AWS::AppSync::GraphQLSchema": {
+		{Name: "DefinitionS3Location"},
+	},
+	"AWS::AppSync::Resolver": {
+		{Name: "RequestMappingTemplateS3Location"},
+		{Name: "ResponseMappingTemplateS3Location"},
+	},
+	"AWS::CloudFormation::Stack": {
+		{Name: "TemplateURL", URL: true, Template: true},
+	},
+	"AWS::ElasticBeanstalk::ApplicationVersion": {
+		{Name: "SourceBundle", Zip: true, Bucket: "S3Bucket", Key: "S3Key"},
+	},
+	"AWS::Lambda::Function": {
+		{Name: "Code", Zip: true, Bucket: "S3Bucket", Key: "S3Key"},
+	},
+	"AWS::Lambda::LayerVersion": {
+		{Name: "Content", Zip: true, Bucket: "S3Bucket", Key: "S3Key"},
+	},
+	"AWS::Serverless::Api": {
+		{Name: "DefinitionUri"},
+	},
+	"AWS::Serverless::Function": {
+		{Name: "CodeUri", Zip: true},
+	},
+	"AWS::Serverless::LayerVersion": {
+		{Name: "ContentUri", Zip: true},
+	},
+	"AWS::StepFunctions::StateMachine": {
+		{Name: "DefinitionS3Location", Bucket: "Bucket", Key: "Key"},
+	},
+}
+
+// zipTime is the modification time recorded for every zipped file so that archives of
+// identical content are identical
+var zipTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Package uploads the local artifacts referenced by each stack template, e.g. lambda
+// code, to the template bucket and rewrites the template to refer to the uploaded
+// objects.  Directories are zipped.  Objects are content addressed so unchanged
+// artifacts are not uploaded again.  Templates that refer to no local artifacts are
+// returned unchanged; rewritten templates are json.
+func (m *Manager) Package(ctx context.Context, stacks ...Stack) ([]Stack, error) {
+	var packaged []Stack
+	for _, s := range stacks {
+		body, changed, err := m.packageTemplate(ctx, filepath.Dir(s.Filename), s.TemplateBody)
+		if err != nil {
+			return nil, fmt.Errorf("unable to package stack, %v: %w", s.Name, err)
+		}
+		if changed {
+			s.TemplateBody = body
+			s.Format = FormatJSON
+		}
+		packaged = append(packaged, s)
+	}
+	return packaged, nil
+}
+
+// packageTemplate uploads the artifacts referenced by the template body, resolving local
+// paths relative to dir, and returns the rewritten template
+func (m *Manager) packageTemplate(ctx context.Context, dir, body string) (string, bool, error) {
+	content, err := parseTemplate(body)
+	if err != nil {
+		return "", false, err
+	}
+
+	resources, _ := content["Resources"].(map[string]interface{})
+
+	var logicalIDs []string
+	for logicalID := range resources {
+		logicalIDs = append(logicalIDs, logicalID)
+	}
+	sort.Strings(logicalIDs)
+
+	changed := false
+	for _, logicalID := range logicalIDs {
+		resource, _ := resources[logicalID].(map[string]interface{})
+		properties, _ := resource["Properties"].(map[string]interface{})
+		for _, prop := range artifactProperties[resourceType(resource)] {
+			filename, ok := localPath(properties[prop.Name])
+			if !ok {
+				continue
+			}
+			if !filepath.IsAbs(filename) {
+				filename = filepath.Join(dir, filename)
+			}
+
+			value, err := m.packageArtifact(ctx, prop, filename)
+			if err != nil {
+				return "", false, fmt.Errorf("unable to package property, %v, of resource, %v: %w", prop.Name, logicalID, err)
+			}
+			properties[prop.Name] = value
+			changed = true
+		}
+	}
+
+	if !changed {
+		return body, false, nil
+	}
+
+	data, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return "", false, fmt.Errorf("unable to encode packaged template: %w", err)
+	}
+
+	return string(data), true, nil
+}
+
+// packageArtifact uploads the local artifact and returns the property value that refers
+// to the uploaded object
+func (m *Manager) packageArtifact(ctx context.Context, prop artifactProperty, filename string) (interface{}, error) {
+	tb := m.options.TemplateBucket
+	if tb == nil || tb.bucket == "" {
+		return nil, fmt.Errorf("unable to package artifact, %v: no bucket configured", filename)
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to package artifact: %w", err)
+	}
+
+	var (
+		data []byte
+		ext  = filepath.Ext(filename)
+	)
+	switch {
+	case prop.Template:
+		body, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read nested template, %v: %w", filename, err)
+		}
+		packaged, _, err := m.packageTemplate(ctx, filepath.Dir(filename), string(body))
+		if err != nil {
+			return nil, fmt.Errorf("unable to package nested template, %v: %w", filename, err)
+		}
+		data, ext = []byte(packaged), ".template"
+
+	case info.IsDir() && !prop.Zip:
+		return nil, fmt.Errorf("unable to package artifact, %v: directory must be a file", filename)
+
+	case info.IsDir() || (prop.Zip && ext != ".zip" && ext != ".jar"):
+		data, err = zipPath(filename)
+		if err != nil {
+			return nil, err
+		}
+		ext = ".zip"
+
+	default:
+		data, err = ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read artifact, %v: %w", filename, err)
+		}
+	}
+
+	key := artifactKey(tb.prefix, data, ext)
+	if err := m.uploadArtifact(ctx, tb, filename, key, data); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case prop.URL:
+		return templateURL(tb.region, tb.bucket, key), nil
+	case prop.Bucket != "":
+		return map[string]interface{}{
+			prop.Bucket: tb.bucket,
+			prop.Key:    key,
+		}, nil
+	default:
+		return "s3://" + tb.bucket + "/" + key, nil
+	}
+}
+
+// uploadArtifact uploads data to key unless an object already exists there
+func (m *Manager) uploadArtifact(ctx context.Context, tb *templateBucket, filename, key string, data []byte) (err error) {
+	if m.options.DryRun {
+		log.Printf("dry run.  would upload artifact, %v -> s3://%v/%v\n", filename, tb.bucket, key)
+		return nil
+	}
+
+	headInput := s3.HeadObjectInput{
+		Bucket: aws.String(tb.bucket),
+		Key:    aws.String(key),
+	}
+	if _, err := tb.api.HeadObjectRequest(&headInput).Send(ctx); err == nil {
+		log.Printf("artifact, %v, unchanged -> s3://%v/%v\n", filename, tb.bucket, key)
+		return nil
+	}
+
+	defer func(begin time.Time) {
+		log.Printf("uploaded artifact, %v -> s3://%v/%v (%v) - %v\n",
+			filename,
+			tb.bucket,
+			key,
+			time.Now().Sub(begin).Round(time.Millisecond),
+			err,
+		)
+	}(time.Now())
+
+	input := s3.PutObjectInput{
+		Body:   bytes.NewReader(data),
+		Bucket: aws.String(tb.bucket),
+		Key:    aws.String(key),
+	}
+	if _, err := tb.api.PutObjectRequest(&input).Send(ctx); err != nil {
+		return fmt.Errorf("unable to upload artifact, %v: %w", filename, err)
+	}
+
+	return nil
+}
+
+// localPath returns the path if v is a string that refers to neither s3 nor a url
+func localPath(v interface{}) (string, bool) {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return "", false
+	}
+	if strings.HasPrefix(s, "s3://") || strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
+		return "", false
+	}
+	return s, true
+}
+
+// artifactKey returns the content addressed key of the artifact
+func artifactKey(prefix string, data []byte, ext string) string {
+	sum := sha256.Sum256(data)
+	return path.Join(prefix, "artifacts", hex.EncodeToString(sum[:])+ext)
+}
+
+// zipPath returns a zip archive of the file or directory.  Entries are sorted and carry
+// a fixed modification time so the archive depends only on names, modes, and content.
+func zipPath(root string) ([]byte, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("unable to zip, %v: %w", root, err)
+	}
+
+	base := root
+	if !info.IsDir() {
+		base = filepath.Dir(root)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	w := zip.NewWriter(buf)
+	walkFn := func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(base, filename)
+		if err != nil {
+			return err
+		}
+
+		mode := os.FileMode(0644)
+		if info.Mode()&0111 != 0 {
+			mode = 0755
+		}
+
+		header := &zip.FileHeader{
+			Name:     filepath.ToSlash(rel),
+			Method:   zip.Deflate,
+			Modified: zipTime,
+		}
+		header.SetMode(mode)
+
+		entry, err := w.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		_, err = entry.Write(data)
+		return err
+	}
+	if err := filepath.Walk(root, walkFn); err != nil {
+		return nil, fmt.Errorf("unable to zip, %v: %w", root, err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("unable to zip, %v: %w", root, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// artifactPaths returns the local paths, resolved relative to dir, that the template body
+// refers to as artifacts or nested templates, including those referred to by nested
+// templates
+func artifactPaths(dir, body string) []string {
+	return appendArtifactPaths(nil, dir, body)
+}
+
+// appendArtifactPaths appends the artifact paths of the template body to paths.  Nested
+// templates already present in paths are not read again.
+func appendArtifactPaths(paths []string, dir, body string) []string {
+	content, err := parseTemplate(body)
+	if err != nil {
+		return paths
+	}
+
+	resources, _ := content["Resources"].(map[string]interface{})
+
+	for _, v := range resources {
+		resource, _ := v.(map[string]interface{})
+		properties, _ := resource["Properties"].(map[string]interface{})
+		for _, prop := range artifactProperties[resourceType(resource)] {
+			filename, ok := localPath(properties[prop.Name])
+			if !ok {
+				continue
+			}
+			if !filepath.IsAbs(filename) {
+				filename = filepath.Join(dir, filename)
+			}
+			if containsString(paths, filename) {
+				continue
+			}
+			paths = append(paths, filename)
+
+			if prop.Template {
+				if data, err := ioutil.ReadFile(filename); err == nil {
+					paths = appendArtifactPaths(paths, filepath.Dir(filename), string(data))
+				}
+			}
+		}
+	}
+	return paths
+}
+
+// isArtifact returns true if the filename is, or lies within, one of the artifact paths
+func isArtifact(filename string, paths []string) bool {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return false
+	}
+	for _, p := range paths {
+		p, err := filepath.Abs(p)
+		if err != nil {
+			continue
+		}
+		if filename == p || strings.HasPrefix(filename, p+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestManager_Package(t *testing.T) {
+	s, err := LoadFile("testdata/package/app.template", WithPrefix("local-example"))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	manager := New(nil,
+		WithDryRun(true),
+		WithTemplateBucket(nil, "us-west-2", "bucket", "prefix"),
+	)
+	stacks, err := manager.Package(context.Background(), s)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := stacks[0].Format, FormatJSON; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	content, err := parseTemplate(stacks[0].TemplateBody)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	property := func(logicalID, name string) interface{} {
+		resources := content["Resources"].(map[string]interface{})
+		properties := resources[logicalID].(map[string]interface{})["Properties"].(map[string]interface{})
+		return properties[name]
+	}
+
+	data, err := zipPath("testdata/package/functions/hello")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	codeKey := artifactKey("prefix", data, ".zip")
+
+	want := map[string]interface{}{"S3Bucket": "bucket", "S3Key": codeKey}
+	if got := property("Function", "Code"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := property("ServerlessFunction", "CodeUri"), "s3://bucket/"+codeKey; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got := property("Api", "BodyS3Location").(map[string]interface{}); !regexp.MustCompile(`^prefix/artifacts/[0-9a-f]{64}\.yaml$`).MatchString(got["Key"].(string)) {
+		t.Fatalf("got %v; want yaml artifact key", got)
+	}
+	if got := property("Child", "TemplateURL").(string); !regexp.MustCompile(`^https://bucket\.s3\.us-west-2\.amazonaws\.com/prefix/artifacts/[0-9a-f]{64}\.template$`).MatchString(got) {
+		t.Fatalf("got %v; want nested template url", got)
+	}
+
+	want = map[string]interface{}{"S3Bucket": "existing", "S3Key": "code.zip"}
+	if got := property("Remote", "Code"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := property("Function", "Role"), map[string]interface{}{"Fn::Sub": "arn:aws:iam::${AWS::AccountId}:role/lambda"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestManager_Package_unchanged(t *testing.T) {
+	s, err := LoadFile("testdata/imports/network.template")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	stacks, err := New(nil).Package(context.Background(), s)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := stacks[0].TemplateBody, s.TemplateBody; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func Test_zipPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zip")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"index.js", "package.json"} {
+		data, err := ioutil.ReadFile(filepath.Join("testdata/package/functions/hello", name))
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	}
+
+	a, err := zipPath(dir)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	now := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "index.js"), now, now); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	b, err := zipPath(dir)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Fatalf("got distinct archives; want identical")
+	}
+}
+
+func Test_isArtifact(t *testing.T) {
+	s, err := LoadFile("testdata/package/app.template")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	paths := artifactPaths(filepath.Dir(s.Filename), s.TemplateBody)
+	testCases := map[string]bool{
+		"testdata/package/api.yaml":                       true,
+		"testdata/package/nested/child.template":          true,
+		"testdata/package/functions/hello/package.json":   true,
+		"testdata/package/app.template":                   false,
+		"testdata/package/functions/hello-world/index.js": false,
+	}
+	for filename, want := range testCases {
+		if got := isArtifact(filename, paths); got != want {
+			t.Fatalf("%v: got %v; want %v", filename, got, want)
+		}
+	}
+}
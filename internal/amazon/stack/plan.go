@@ -0,0 +1,177 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/fatih/color"
+)
+
+// ResourceChange describes a single resource change reported by a change set
+type ResourceChange struct {
+	Action       cloudformation.ChangeAction
+	LogicalID    string
+	PhysicalID   string
+	ResourceType string
+	Replacement  cloudformation.Replacement
+	Scope        []cloudformation.ResourceAttribute
+}
+
+// IsReplacement returns true if cloudformation will (or may) replace the resource
+func (r ResourceChange) IsReplacement() bool {
+	return r.Replacement == cloudformation.ReplacementTrue || r.Replacement == cloudformation.ReplacementConditional
+}
+
+// Plan holds the reviewable set of changes cloudformation intends to make to a stack
+type Plan struct {
+	Operation     Operation
+	StackName     string
+	ChangeSetName string
+	Changes       []ResourceChange
+}
+
+// HasChanges returns true if executing the plan would modify the stack
+func (p Plan) HasChanges() bool {
+	return len(p.Changes) > 0
+}
+
+// HasReplacements returns true if any resource in the plan will (or may) be replaced
+func (p Plan) HasReplacements() bool {
+	for _, c := range p.Changes {
+		if c.IsReplacement() {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Plan) String() string {
+	return fmt.Sprintf("%v %v (%v resource changes)", p.Operation, p.StackName, len(p.Changes))
+}
+
+func makeResourceChanges(changes []cloudformation.Change) []ResourceChange {
+	var items []ResourceChange
+	for _, c := range changes {
+		rc := c.ResourceChange
+		if rc == nil {
+			continue
+		}
+		items = append(items, ResourceChange{
+			Action:       rc.Action,
+			LogicalID:    aws.StringValue(rc.LogicalResourceId),
+			PhysicalID:   aws.StringValue(rc.PhysicalResourceId),
+			ResourceType: aws.StringValue(rc.ResourceType),
+			Replacement:  rc.Replacement,
+			Scope:        rc.Scope,
+		})
+	}
+	return items
+}
+
+// Plan writes the plan, one line per resource change.  The lines of a plan are written
+// together so plans of stacks planned concurrently do not interleave.
+func (c *Console) Plan(plan Plan) {
+	prefix := c.prefix(plan.StackName)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !plan.HasChanges() {
+		fmt.Fprintf(c.w, "%v%v: no changes\n", prefix, plan.StackName)
+		return
+	}
+
+	fmt.Fprintf(c.w, "%v%v: %v resource changes\n", prefix, plan.StackName, len(plan.Changes))
+	for _, rc := range plan.Changes {
+		var scope []string
+		for _, s := range rc.Scope {
+			scope = append(scope, string(s))
+		}
+
+		replacement := string(rc.Replacement)
+		if replacement == "" {
+			replacement = "-"
+		}
+
+		text := prefix + fmt.Sprintf("  %-8s %-25s %-35s %-12s %s\n",
+			rc.Action,
+			rc.LogicalID,
+			rc.ResourceType,
+			replacement,
+			strings.Join(scope, ","),
+		)
+
+		changeColor(rc).Fprint(c.w, text)
+	}
+}
+
+// changeColor returns the color of console lines for the resource change
+func changeColor(rc ResourceChange) *color.Color {
+	switch {
+	case rc.Action == cloudformation.ChangeActionRemove || rc.IsReplacement():
+		return color.New(color.FgRed)
+	case rc.Action == cloudformation.ChangeActionModify:
+		return color.New(color.FgYellow)
+	case rc.Action == cloudformation.ChangeActionAdd:
+		return color.New(color.FgGreen)
+	default:
+		return color.New(color.FgBlue)
+	}
+}
+
+// Summary counts the stacks affected by a set of plans
+type Summary struct {
+	Inserts      int
+	Updates      int
+	Replacements int
+	Deletes      int
+	Unchanged    int
+}
+
+// Summarize the plans provided.  Updates that replace one or more resources are
+// counted as both updates and replacements.
+func Summarize(plans ...Plan) Summary {
+	var s Summary
+	for _, plan := range plans {
+		switch {
+		case plan.Operation == Insert:
+			s.Inserts++
+		case plan.Operation == Delete:
+			s.Deletes++
+		case !plan.HasChanges():
+			s.Unchanged++
+		default:
+			s.Updates++
+			if plan.HasReplacements() {
+				s.Replacements++
+			}
+		}
+	}
+	return s
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%v to insert, %v to update (%v with replacements), %v to delete, %v unchanged",
+		s.Inserts,
+		s.Updates,
+		s.Replacements,
+		s.Deletes,
+		s.Unchanged,
+	)
+}
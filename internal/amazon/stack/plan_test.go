@@ -0,0 +1,256 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/fatih/color"
+)
+
+func TestPlan(t *testing.T) {
+	changes := makeResourceChanges([]cloudformation.Change{
+		{
+			ResourceChange: &cloudformation.ResourceChange{
+				Action:            cloudformation.ChangeActionModify,
+				LogicalResourceId: aws.String("Table"),
+				ResourceType:      aws.String("AWS::DynamoDB::Table"),
+				Replacement:       cloudformation.ReplacementTrue,
+				Scope:             []cloudformation.ResourceAttribute{cloudformation.ResourceAttributeProperties},
+			},
+		},
+		{
+			ResourceChange: &cloudformation.ResourceChange{
+				Action:            cloudformation.ChangeActionAdd,
+				LogicalResourceId: aws.String("Queue"),
+				ResourceType:      aws.String("AWS::SQS::Queue"),
+			},
+		},
+		{}, // no resource change
+	})
+	if got, want := len(changes), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	plan := Plan{StackName: "abc", Changes: changes}
+	if !plan.HasChanges() {
+		t.Fatalf("got false; want true")
+	}
+	if !plan.HasReplacements() {
+		t.Fatalf("got false; want true")
+	}
+
+	plan.Changes = changes[1:]
+	if plan.HasReplacements() {
+		t.Fatalf("got true; want false")
+	}
+}
+
+func Test_isNoChanges(t *testing.T) {
+	testCases := map[string]bool{
+		"The submitted information didn't contain changes. Submit different information to create a change set.": true,
+		"No updates are to be performed.": true,
+		"Template format error: Unresolved resource dependencies [Foo] in the Resources block of the template": false,
+	}
+
+	for reason, want := range testCases {
+		if got := isNoChanges(reason); got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	replaced := ResourceChange{Action: cloudformation.ChangeActionModify, Replacement: cloudformation.ReplacementTrue}
+	modified := ResourceChange{Action: cloudformation.ChangeActionModify, Replacement: cloudformation.ReplacementFalse}
+
+	summary := Summarize(
+		Plan{Operation: Insert, StackName: "a"},
+		Plan{Operation: Update, StackName: "b", Changes: []ResourceChange{modified}},
+		Plan{Operation: Update, StackName: "c", Changes: []ResourceChange{modified, replaced}},
+		Plan{Operation: Update, StackName: "d"},
+		Plan{Operation: Delete, StackName: "e"},
+	)
+	want := Summary{
+		Inserts:      1,
+		Updates:      2,
+		Replacements: 1,
+		Deletes:      1,
+		Unchanged:    1,
+	}
+	if got := summary; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestManager_Plan_abandon(t *testing.T) {
+	testCases := map[string]struct {
+		Operation Operation
+		Status    cloudformation.ChangeSetStatus
+		Reason    string
+		WaitErr   error
+		WantErr   bool
+		Want      []string
+	}{
+		"failed insert": {
+			Operation: Insert,
+			Status:    cloudformation.ChangeSetStatusFailed,
+			Reason:    "Template format error",
+			WaitErr:   errors.New("failed"),
+			WantErr:   true,
+			Want:      []string{"DeleteChangeSet app", "DeleteStack app"},
+		},
+		"failed update": {
+			Operation: Update,
+			Status:    cloudformation.ChangeSetStatusFailed,
+			Reason:    "Template format error",
+			WaitErr:   errors.New("failed"),
+			WantErr:   true,
+			Want:      []string{"DeleteChangeSet app"},
+		},
+		"wait failed": {
+			Operation: Update,
+			Status:    cloudformation.ChangeSetStatusCreatePending,
+			WaitErr:   context.DeadlineExceeded,
+			WantErr:   true,
+			Want:      []string{"DeleteChangeSet app"},
+		},
+		"no changes": {
+			Operation: Update,
+			Status:    cloudformation.ChangeSetStatusFailed,
+			Reason:    "No updates are to be performed.",
+			WaitErr:   errors.New("failed"),
+			Want:      []string{"DeleteChangeSet app"},
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			s, err := LoadFile("testdata/a/table.template")
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			s.Name = "app"
+
+			api := &stubAPI{
+				ChangeSetStatus: tc.Status,
+				ChangeSetReason: tc.Reason,
+				WaitErr:         tc.WaitErr,
+			}
+			_, err = New(api).Plan(context.Background(), Change{Operation: tc.Operation, Stack: s})
+			if got, want := err != nil, tc.WantErr; got != want {
+				t.Fatalf("got %v; want %v", err, want)
+			}
+
+			var got []string
+			for _, call := range api.Calls() {
+				if strings.HasPrefix(call, "Delete") {
+					got = append(got, call)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.Want) {
+				t.Fatalf("got %v; want %v", got, tc.Want)
+			}
+		})
+	}
+}
+
+func TestManager_Update(t *testing.T) {
+	s, err := LoadFile("testdata/a/table.template")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	s.Name = "app"
+
+	t.Run("outputs only", func(t *testing.T) {
+		// a change set that changes only outputs lists no resource changes
+		api := &stubAPI{
+			ChangeSetStatus: cloudformation.ChangeSetStatusCreateComplete,
+			StackStatus:     cloudformation.StackStatusUpdateComplete,
+		}
+		if err := New(api).Update(context.Background(), s); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if !api.Called("ExecuteChangeSet") {
+			t.Fatalf("got false; want true")
+		}
+		if api.Called("DeleteChangeSet") {
+			t.Fatalf("got true; want false")
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		api := &stubAPI{}
+		if err := New(api, WithDryRun(true)).Update(context.Background(), s); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got := api.Calls(); len(got) != 0 {
+			t.Fatalf("got %v; want no calls", got)
+		}
+	})
+}
+
+func TestConsole_Plan(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	changes := []ResourceChange{
+		{Action: cloudformation.ChangeActionAdd, LogicalID: "Queue", ResourceType: "AWS::SQS::Queue"},
+		{Action: cloudformation.ChangeActionModify, LogicalID: "Table", ResourceType: "AWS::DynamoDB::Table"},
+	}
+
+	var (
+		buf     = bytes.NewBuffer(nil)
+		console = NewConsole(buf, true)
+		wg      sync.WaitGroup
+		names   = []string{"a", "b", "c", "d"}
+	)
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			console.Plan(Plan{StackName: name, Changes: changes})
+		}(name)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if got, want := len(lines), len(names)*(len(changes)+1); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	for i := 0; i < len(lines); i += len(changes) + 1 {
+		prefix := lines[i][:strings.Index(lines[i], "]")+1]
+		for _, line := range lines[i : i+len(changes)+1] {
+			if !strings.HasPrefix(line, prefix) {
+				t.Fatalf("got %v; want lines of plan prefixed by %v", line, prefix)
+			}
+		}
+	}
+
+	buf.Reset()
+	NewConsole(buf, false).Plan(Plan{StackName: "a"})
+	if got, want := buf.String(), "a: no changes\n"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// pollInterval is the interval between stack status checks while waiting on a stack
+const pollInterval = 6 * time.Second
+
+var (
+	// unrecoverableStatus holds the statuses of stacks whose initial create failed.  Such
+	// stacks can never be updated and must be deleted before being created again.
+	unrecoverableStatus = []cloudformation.StackStatus{
+		cloudformation.StackStatusRollbackComplete,
+		cloudformation.StackStatusRollbackFailed,
+	}
+)
+
+// isInProgress returns true if cloudformation is actively operating on the stack
+func isInProgress(status cloudformation.StackStatus) bool {
+	return strings.HasSuffix(string(status), "_IN_PROGRESS") && status != cloudformation.StackStatusReviewInProgress
+}
+
+// needsCreate returns true if a change to a stack with the given status must be applied
+// as a create rather than an update
+func needsCreate(status cloudformation.StackStatus) bool {
+	return status == cloudformation.StackStatusReviewInProgress ||
+		status == cloudformation.StackStatusDeleteComplete ||
+		containsStatus(unrecoverableStatus, status)
+}
+
+// recover brings the stack into a state where the change can be applied.  In progress
+// operations are waited on, stacks whose initial create failed are deleted so they may
+// be created again, and failed update rollbacks are continued.  The returned change
+// reflects the operation that should be applied; a delete of a stack that no longer
+// exists is returned with status DELETE_COMPLETE.
+func (m *Manager) recover(ctx context.Context, change Change) (Change, error) {
+	name := change.Stack.Name
+	for {
+		status := change.Status
+		switch {
+		case status == "":
+			return change, nil
+
+		case isInProgress(status):
+			log.Printf("stack, %v, is %v; waiting for operation to complete\n", name, status)
+			v, err := m.waitForStack(ctx, name)
+			if err != nil {
+				return Change{}, fmt.Errorf("unable to recover stack, %v: %w", name, err)
+			}
+			change.Status = v
+
+		case change.Operation == Delete:
+			return change, nil
+
+		case status == cloudformation.StackStatusDeleteComplete || status == cloudformation.StackStatusReviewInProgress:
+			change.Operation = Insert
+			change.Status = ""
+			return change, nil
+
+		case containsStatus(unrecoverableStatus, status):
+			log.Printf("stack, %v, is %v; deleting before it is created again\n", name, status)
+			if err := m.deleteStack(ctx, change.Stack); err != nil {
+				return Change{}, fmt.Errorf("unable to recover stack, %v: %w", name, err)
+			}
+			if m.options.DryRun {
+				change.Operation = Insert
+				change.Status = ""
+				return change, nil
+			}
+			v, err := m.waitForStack(ctx, name)
+			if err != nil {
+				return Change{}, fmt.Errorf("unable to recover stack, %v: %w", name, err)
+			}
+			if v != cloudformation.StackStatusDeleteComplete {
+				return Change{}, fmt.Errorf("unable to recover stack, %v: delete finished with status, %v", name, v)
+			}
+			change.Status = v
+
+		case status == cloudformation.StackStatusUpdateRollbackFailed:
+			v, err := m.continueUpdateRollback(ctx, name)
+			if err != nil {
+				return Change{}, fmt.Errorf("unable to recover stack, %v: %w", name, err)
+			}
+			if v == cloudformation.StackStatusUpdateRollbackFailed {
+				return Change{}, fmt.Errorf("unable to recover stack, %v: rollback failed again; resources that cannot be rolled back may be skipped", name)
+			}
+			change.Status = v
+
+		default:
+			return change, nil
+		}
+	}
+}
+
+// continueUpdateRollback resumes the failed rollback of the stack, skipping the resources
+// configured via WithResourcesToSkip, and waits for the rollback to complete
+func (m *Manager) continueUpdateRollback(ctx context.Context, stackName string) (cloudformation.StackStatus, error) {
+	skip := m.options.ResourcesToSkip[stackName]
+	log.Printf("stack, %v, is %v; continuing update rollback (skipping resources: %v)\n",
+		stackName,
+		cloudformation.StackStatusUpdateRollbackFailed,
+		skip,
+	)
+
+	if m.options.DryRun {
+		log.Printf("dry run.  continue update rollback not applied for stack, %v\n", stackName)
+		return cloudformation.StackStatusUpdateRollbackComplete, nil
+	}
+
+	input := cloudformation.ContinueUpdateRollbackInput{
+		ResourcesToSkip: skip,
+		StackName:       aws.String(stackName),
+	}
+	if _, err := m.api.ContinueUpdateRollbackRequest(&input).Send(ctx); err != nil {
+		return "", fmt.Errorf("failed to continue update rollback for stack, %v: %w", stackName, err)
+	}
+
+	return m.waitForStack(ctx, stackName)
+}
+
+// waitForStack polls the stack until no operation is in progress and returns its final
+// status.  Stacks that no longer exist are reported as DELETE_COMPLETE.
+func (m *Manager) waitForStack(ctx context.Context, stackName string) (cloudformation.StackStatus, error) {
+	for {
+		status, err := m.stackStatus(ctx, stackName)
+		if err != nil {
+			return "", err
+		}
+		if !isInProgress(status) {
+			return status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// stackStatus returns the current status of the stack
+func (m *Manager) stackStatus(ctx context.Context, stackName string) (cloudformation.StackStatus, error) {
+	input := cloudformation.DescribeStacksInput{
+		StackName: aws.String(stackName),
+	}
+	resp, err := m.api.DescribeStacksRequest(&input).Send(ctx)
+	if err != nil {
+		var ae awserr.Error
+		if ok := errors.As(err, &ae); ok && ae.Code() == errValidationError {
+			if strings.Contains(ae.Message(), "does not exist") {
+				return cloudformation.StackStatusDeleteComplete, nil
+			}
+		}
+		return "", fmt.Errorf("unable to describe stack, %v: %w", stackName, err)
+	}
+	if len(resp.Stacks) == 0 {
+		return cloudformation.StackStatusDeleteComplete, nil
+	}
+	return resp.Stacks[0].StackStatus, nil
+}
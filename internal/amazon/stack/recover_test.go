@@ -0,0 +1,77 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+func Test_isInProgress(t *testing.T) {
+	testCases := map[cloudformation.StackStatus]bool{
+		cloudformation.StackStatusCreateInProgress:                        true,
+		cloudformation.StackStatusUpdateRollbackCompleteCleanupInProgress: true,
+		cloudformation.StackStatusDeleteInProgress:                        true,
+		cloudformation.StackStatusReviewInProgress:                        false,
+		cloudformation.StackStatusRollbackComplete:                        false,
+		cloudformation.StackStatusUpdateRollbackFailed:                    false,
+	}
+
+	for status, want := range testCases {
+		if got := isInProgress(status); got != want {
+			t.Fatalf("%v: got %v; want %v", status, got, want)
+		}
+	}
+}
+
+func TestManager_recover(t *testing.T) {
+	manager := New(nil)
+
+	testCases := map[string]struct {
+		Change        Change
+		WantOperation Operation
+	}{
+		"new": {
+			Change:        Change{Operation: Insert},
+			WantOperation: Insert,
+		},
+		"healthy": {
+			Change:        Change{Operation: Update, Status: cloudformation.StackStatusUpdateComplete},
+			WantOperation: Update,
+		},
+		"review": {
+			Change:        Change{Operation: Update, Status: cloudformation.StackStatusReviewInProgress},
+			WantOperation: Insert,
+		},
+		"delete": {
+			Change:        Change{Operation: Delete, Status: cloudformation.StackStatusRollbackComplete},
+			WantOperation: Delete,
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			change, err := manager.recover(context.Background(), tc.Change)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			if got, want := change.Operation, tc.WantOperation; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/sanathkr/go-yaml"
+)
+
+const (
+	// renderSuffix identifies templates rendered with text/template e.g. queues.yaml.tmpl
+	renderSuffix = ".tmpl"
+	// renderHeader, when it is the first line of a template, identifies the template as
+	// one to be rendered with text/template
+	renderHeader = "# fairy:template"
+)
+
+// TemplateData holds the values available to templates rendered with text/template
+type TemplateData struct {
+	Env        string
+	Project    string
+	Version    string
+	Parameters map[string]string
+	// Exports holds the bootstrap exports e.g. AssetBucket
+	Exports map[string]string
+}
+
+// isRendered returns true if the template should be rendered with text/template
+func isRendered(filename string, body []byte) bool {
+	if strings.HasSuffix(filename, renderSuffix) {
+		return true
+	}
+	line := body
+	if i := bytes.IndexByte(body, '\n'); i >= 0 {
+		line = body[:i]
+	}
+	return strings.TrimSpace(string(line)) == renderHeader
+}
+
+// isPartial returns true if the file is only meant to be included by other templates
+func isPartial(filename string) bool {
+	return strings.HasPrefix(filepath.Base(filename), "_")
+}
+
+// trimExt removes the file extension, and the render suffix if any, from the filename
+// e.g. queues.yaml.tmpl becomes queues
+func trimExt(filename string) string {
+	filename = strings.TrimSuffix(filename, renderSuffix)
+	return strings.TrimSuffix(filename, filepath.Ext(filename))
+}
+
+// render the template body with text/template.  Templates included via the include
+// function are read relative to the directory of filename.
+func render(filename string, body []byte, data TemplateData) ([]byte, error) {
+	dir := filepath.Dir(filename)
+
+	var include includeFunc
+	include = func(name string, data interface{}) (string, error) {
+		path := filepath.Join(dir, name)
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("unable to include template, %v: %w", name, err)
+		}
+		rendered, err := execute(path, content, data, include)
+		if err != nil {
+			return "", err
+		}
+		return string(rendered), nil
+	}
+
+	return execute(filename, body, data, include)
+}
+
+// includeFunc renders the named template with the data provided
+type includeFunc func(name string, data interface{}) (string, error)
+
+func execute(filename string, body []byte, data interface{}, include includeFunc) ([]byte, error) {
+	funcs := template.FuncMap{
+		"env":         os.Getenv,
+		"include":     include,
+		"indent":      indent,
+		"join":        strings.Join,
+		"lower":       strings.ToLower,
+		"requiredEnv": requiredEnv,
+		"split":       strings.Split,
+		"title":       strings.Title,
+		"toJson":      toJSON,
+		"toYaml":      toYAML,
+		"upper":       strings.ToUpper,
+	}
+
+	t, err := template.New(filepath.Base(filename)).
+		Option("missingkey=error").
+		Funcs(funcs).
+		Parse(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse template, %v: %w", filename, err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := t.Execute(buf, data); err != nil {
+		return nil, fmt.Errorf("unable to render template, %v: %w", filename, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// indent prefixes each line of s with n spaces
+func indent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.Replace(s, "\n", "\n"+pad, -1)
+}
+
+// requiredEnv returns the value of the environment variable or an error if it is blank
+func requiredEnv(name string) (string, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return "", fmt.Errorf("environment variable, %v, is required", name)
+	}
+	return v, nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func toYAML(v interface{}) (string, error) {
+	parseMutex.Lock()
+	defer parseMutex.Unlock()
+
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
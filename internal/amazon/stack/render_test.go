@@ -0,0 +1,115 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLoadAll_render(t *testing.T) {
+	opts := []Option{
+		WithPrefix("local-example"),
+		WithProject("example"),
+		WithParameters(map[string]string{Env: "local", Version: "abc", "Queues": "orders,events"}),
+		WithBootstrapExports(map[string]string{"AssetBucket": "assets"}),
+	}
+	stacks, err := LoadAll("testdata/render", opts...)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	want := []string{"local-example-queues", "local-example-version"}
+	if got := stackNames(stacks); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	content, err := parseTemplate(stacks[0].TemplateBody)
+	if err != nil {
+		t.Fatalf("got %v; want nil\n%v", err, stacks[0].TemplateBody)
+	}
+	resources, _ := content["Resources"].(map[string]interface{})
+
+	var got []string
+	for logicalID := range resources {
+		got = append(got, logicalID)
+	}
+	wantIDs := []string{"EventsAlarm", "EventsQueue", "OrdersAlarm", "OrdersQueue"}
+	if !reflect.DeepEqual(sortStrings(got), wantIDs) {
+		t.Fatalf("got %v; want %v", got, wantIDs)
+	}
+	if !strings.Contains(stacks[0].TemplateBody, "QueueName: 'local-example-orders'") {
+		t.Fatalf("got %v; want rendered queue name", stacks[0].TemplateBody)
+	}
+	if !strings.Contains(stacks[0].TemplateBody, "Value: 'assets'") {
+		t.Fatalf("got %v; want rendered export", stacks[0].TemplateBody)
+	}
+	if !strings.Contains(stacks[1].TemplateBody, "TopicName: 'topic-abc'") {
+		t.Fatalf("got %v; want rendered version", stacks[1].TemplateBody)
+	}
+}
+
+func Test_render(t *testing.T) {
+	t.Run("missing key", func(t *testing.T) {
+		_, err := render("testdata/render/x.yaml.tmpl", []byte("{{ .Parameters.Missing }}"), TemplateData{Parameters: map[string]string{}})
+		if err == nil {
+			t.Fatalf("got nil; want err")
+		}
+	})
+
+	t.Run("requiredEnv", func(t *testing.T) {
+		_, err := render("testdata/render/x.yaml.tmpl", []byte(`{{ requiredEnv "FAIRY_TEST_UNSET" }}`), TemplateData{})
+		if err == nil {
+			t.Fatalf("got nil; want err")
+		}
+	})
+
+	t.Run("toYaml", func(t *testing.T) {
+		data := TemplateData{Parameters: map[string]string{"A": "1"}}
+		got, err := render("testdata/render/x.yaml.tmpl", []byte(`{{ toYaml .Parameters }}`), data)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if want := `A: "1"`; string(got) != want {
+			t.Fatalf("got %v; want %v", string(got), want)
+		}
+	})
+}
+
+func Test_isTemplate(t *testing.T) {
+	testCases := map[string]bool{
+		"table.template":      true,
+		"table.yaml":          true,
+		"table.yml.tmpl":      true,
+		"table.json":          true,
+		"table.config.yaml":   false,
+		"_alarm.yaml":         false,
+		"_alarm.yaml.tmpl":    false,
+		"dir/_alarm.template": false,
+		"README.md":           false,
+	}
+	for filename, want := range testCases {
+		if got := isTemplate(filename); got != want {
+			t.Fatalf("%v: got %v; want %v", filename, got, want)
+		}
+	}
+}
+
+func sortStrings(ss []string) []string {
+	sort.Strings(ss)
+	return ss
+}
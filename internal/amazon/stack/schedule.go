@@ -0,0 +1,111 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// schedule invokes fn for each node once the nodes it depends on have completed, running
+// up to concurrency invocations at a time.  Ready nodes are started in their original
+// order so a concurrency of 1 processes nodes exactly as provided.  After the first
+// failure no further nodes are started; invocations already running are allowed to
+// finish so no stack is abandoned mid-operation.
+func schedule(ctx context.Context, concurrency int, nodes []node, fn func(ctx context.Context, i int) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	index := map[string]int{}
+	for i, n := range nodes {
+		index[n.name] = i
+	}
+
+	var (
+		pending    = make([]int, len(nodes))
+		dependents = make([][]int, len(nodes))
+		ready      []int
+	)
+	for i, n := range nodes {
+		for _, name := range n.dependsOn {
+			if j, ok := index[name]; ok && j != i {
+				pending[i]++
+				dependents[j] = append(dependents[j], i)
+			}
+		}
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	type result struct {
+		i   int
+		err error
+	}
+
+	var (
+		results   = make(chan result)
+		running   = 0
+		completed = 0
+		errs      []error
+	)
+	for {
+		for len(errs) == 0 && ctx.Err() == nil && running < concurrency && len(ready) > 0 {
+			i := ready[0]
+			ready = ready[1:]
+			running++
+
+			go func(i int) {
+				results <- result{i: i, err: fn(ctx, i)}
+			}(i)
+		}
+		if running == 0 {
+			break
+		}
+
+		r := <-results
+		running--
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+
+		completed++
+		for _, j := range dependents[r.i] {
+			if pending[j]--; pending[j] == 0 {
+				ready = append(ready, j)
+			}
+		}
+		sort.Ints(ready)
+	}
+
+	if len(errs) > 0 {
+		for _, err := range errs[1:] {
+			log.Printf("additional failure: %v\n", err)
+		}
+		return errs[0]
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if completed < len(nodes) {
+		return fmt.Errorf("%v of %v stacks could not be scheduled: %w", len(nodes)-completed, len(nodes), ErrCycle)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_schedule(t *testing.T) {
+	nodes := []node{
+		{name: "network"},
+		{name: "table", dependsOn: []string{"network"}},
+		{name: "queue"},
+		{name: "api", dependsOn: []string{"table", "queue"}},
+	}
+
+	t.Run("serial", func(t *testing.T) {
+		var got []string
+		fn := func(ctx context.Context, i int) error {
+			got = append(got, nodes[i].name)
+			return nil
+		}
+		if err := schedule(context.Background(), 1, nodes, fn); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if want := []string{"network", "table", "queue", "api"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		var (
+			mutex   sync.Mutex
+			done    = map[string]bool{}
+			running int
+			peak    int
+		)
+		fn := func(ctx context.Context, i int) error {
+			mutex.Lock()
+			for _, dep := range nodes[i].dependsOn {
+				if !done[dep] {
+					t.Errorf("%v started before dependency, %v", nodes[i].name, dep)
+				}
+			}
+			running++
+			if running > peak {
+				peak = running
+			}
+			mutex.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mutex.Lock()
+			running--
+			done[nodes[i].name] = true
+			mutex.Unlock()
+			return nil
+		}
+		if err := schedule(context.Background(), 4, nodes, fn); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := len(done), len(nodes); got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := peak, 2; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		boom := errors.New("boom")
+
+		var mutex sync.Mutex
+		var got []string
+		fn := func(ctx context.Context, i int) error {
+			mutex.Lock()
+			got = append(got, nodes[i].name)
+			mutex.Unlock()
+
+			if nodes[i].name == "network" {
+				return boom
+			}
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}
+		if err := schedule(context.Background(), 2, nodes, fn); !errors.Is(err, boom) {
+			t.Fatalf("got %v; want %v", err, boom)
+		}
+		sort.Strings(got)
+		if want := []string{"network", "queue"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
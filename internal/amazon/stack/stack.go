@@ -18,9 +18,10 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
-	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 )
@@ -41,11 +42,16 @@ const (
 	Insert Operation = "insert"
 	Update Operation = "update"
 	Delete Operation = "delete"
+	// Import brings existing resources under management of the stack
+	Import Operation = "import"
 )
 
 type Change struct {
 	Operation Operation
 	Stack     Stack
+
+	// Status holds the status of the deployed stack, if any, when the change was calculated
+	Status cloudformation.StackStatus
 }
 
 func (c Change) String() string {
@@ -56,8 +62,66 @@ type Stack struct {
 	Name         string
 	Tags         []cloudformation.Tag
 	TemplateBody string
+	Format       Format
+
+	// Filename holds the file the template was loaded from
+	Filename string
+
+	// Exports holds the names of the exports declared in the template outputs
+	Exports []string
+	// Imports holds the export names referenced by Fn::ImportValue
+	Imports []string
+	// DependsOn holds the names of the stacks whose exports this stack imports or whose
+	// outputs it receives as parameters
+	DependsOn []string
+
+	// Namespace prefixes the parameter names the stack outputs are published as e.g.
+	// the output VpcId of network.template is published as NetworkVpcId
+	Namespace string
+	// ParameterKeys holds the names of the parameters declared in the template
+	ParameterKeys []string
+	// OutputKeys holds the names of the outputs declared in the template
+	OutputKeys []string
+	// OutputParameters maps output keys to additional parameter names they are published as
+	OutputParameters map[string]string
+
+	// ResourcesToImport holds existing resources the stack takes over before it is applied
+	ResourcesToImport []cloudformation.ResourceToImport
+
+	// Parameters holds stack specific parameters that replace global parameters of the same name
+	Parameters map[string]string
+	// Capabilities holds the capabilities acknowledged in addition to those the template requires
+	Capabilities []cloudformation.Capability
+	// TerminationProtection, if not nil, enables or disables termination protection
+	TerminationProtection *bool
+	// StackPolicy holds the json stack policy, if any
+	StackPolicy string
+	// NotificationARNs holds the sns topics that receive stack events
+	NotificationARNs []string
+	// Timeout bounds how long a create, update, or delete may take; zero means no limit
+	Timeout time.Duration
+	// Environments, if set, restricts the stack to the environments listed
+	Environments []string
+	// ExcludeEnvironments lists environments the stack should not be deployed to
+	ExcludeEnvironments []string
 }
 
+// Enabled returns true if the stack should be deployed to the environment provided.
+// All stacks are enabled when env is blank.
+func (s Stack) Enabled(env string) bool {
+	if env == "" {
+		return true
+	}
+	if len(s.Environments) > 0 && !containsString(s.Environments, env) {
+		return false
+	}
+	return !containsString(s.ExcludeEnvironments, env)
+}
+
+// Load the stack from the template body provided.  If a sidecar config, e.g.
+// table.config.yaml for table.template, exists next to filename, its settings are
+// applied to the stack.  Templates whose filename ends in .tmpl, or whose first line
+// is "# fairy:template", are first rendered with text/template.
 func Load(filename string, body io.Reader, opts ...Option) (Stack, error) {
 	var (
 		options = buildOptions(opts...)
@@ -69,17 +133,65 @@ func Load(filename string, body io.Reader, opts ...Option) (Stack, error) {
 		return Stack{}, fmt.Errorf("unable to read template from file, %v: %w", filename, err)
 	}
 
-	return Stack{
+	config, err := loadConfig(filename)
+	if err != nil {
+		return Stack{}, err
+	}
+
+	if isRendered(filename, data) {
+		templateData := TemplateData{
+			Env:        options.Parameters[Env],
+			Project:    options.Project,
+			Version:    options.Parameters[Version],
+			Parameters: mergeParameters(options.Parameters, config.Parameters),
+			Exports:    options.BootstrapExports,
+		}
+		data, err = render(filename, data, templateData)
+		if err != nil {
+			return Stack{}, err
+		}
+	}
+
+	stack := Stack{
+		Filename:     filename,
 		Name:         options.Prefix + options.FormatName(name),
+		Namespace:    makeNamespace(name),
 		TemplateBody: string(data),
+		Format:       DetectFormat(string(data)),
 		Tags:         options.Tags,
-	}, nil
+	}
+	if err := config.apply(&stack); err != nil {
+		return Stack{}, fmt.Errorf("unable to configure stack from file, %v: %w", filename, err)
+	}
+
+	params := mergeParameters(options.Parameters, stack.Parameters)
+	stack.Exports, stack.Imports, err = inspectTemplate(stack.Name, stack.TemplateBody, params)
+	if err != nil {
+		return Stack{}, fmt.Errorf("unable to read template from file, %v: %w", filename, err)
+	}
+
+	stack.ParameterKeys, stack.OutputKeys, err = templateKeys(stack.TemplateBody)
+	if err != nil {
+		return Stack{}, fmt.Errorf("unable to read template from file, %v: %w", filename, err)
+	}
+
+	return stack, nil
+}
+
+// mergeParameters returns the union of the parameter sets provided.  When names collide,
+// later values replace earlier ones.
+func mergeParameters(sets ...map[string]string) map[string]string {
+	params := map[string]string{}
+	for _, set := range sets {
+		for k, v := range set {
+			params[k] = v
+		}
+	}
+	return params
 }
 
 func makeStackName(filename string) string {
-	ext := filepath.Ext(filename)
-	name := filepath.Base(filename)
-	return name[0 : len(name)-len(ext)]
+	return filepath.Base(trimExt(filename))
 }
 
 func LoadFile(filename string, opts ...Option) (Stack, error) {
@@ -92,23 +204,65 @@ func LoadFile(filename string, opts ...Option) (Stack, error) {
 	return Load(filename, f, opts...)
 }
 
-// LoadAll stacks from the directory provided
-func LoadAll(dirname string, opts ...Option) ([]Stack, error) {
-	const suffix = ".template"
+// isTemplateFile returns true if the file declares the keys of a cloudformation template.
+// Files that are rendered can only be checked once rendered so they are assumed to be
+// templates here.
+func isTemplateFile(filename string) (bool, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return false, fmt.Errorf("unable to read template, %v: %w", filename, err)
+	}
+	return isRendered(filename, data) || isTemplateBody(string(data)), nil
+}
 
-	var stacks []Stack
+// LoadAll stacks from the directory provided.  Files ending in .template are loaded as
+// templates, as are files at the top level of the directory ending in .yaml, .yml, or
+// .json that declare Resources or AWSTemplateFormatVersion; json and yaml formats are
+// detected from content.
+// Stacks not enabled for the Env parameter are skipped, as are files that another
+// template refers to as an artifact or nested template.  Stacks are ordered such that
+// each stack follows the stacks whose exports it imports.
+func LoadAll(dirname string, opts ...Option) ([]Stack, error) {
+	var (
+		options = buildOptions(opts...)
+		env     = options.Parameters[Env]
+		stacks  []Stack
+	)
 	fn := func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if !strings.HasSuffix(path, suffix) {
+		if info.IsDir() || !isTemplate(path) {
+			return nil
+		}
+		topLevel := filepath.Dir(path) == filepath.Clean(dirname)
+		if !topLevel && !hasTemplateSuffix(path) {
 			return nil
 		}
 
+		if !hasTemplateSuffix(path) {
+			ok, err := isTemplateFile(path)
+			if err != nil {
+				return fmt.Errorf("unable to read dir, %v: %w", dirname, err)
+			}
+			if !ok {
+				log.Printf("skipping %v.  file is not a cloudformation template\n", path)
+				return nil
+			}
+		}
+
 		stack, err := LoadFile(path, opts...)
 		if err != nil {
 			return fmt.Errorf("unable to read dir, %v: %w", dirname, err)
 		}
+		if !hasTemplateSuffix(path) && !isTemplateBody(stack.TemplateBody) {
+			log.Printf("skipping %v.  file is not a cloudformation template\n", path)
+			return nil
+		}
+		if !stack.Enabled(env) {
+			log.Printf("skipping stack, %v.  stack is not enabled for env, %v\n", stack.Name, env)
+			return nil
+		}
 
 		stacks = append(stacks, stack)
 
@@ -121,5 +275,24 @@ func LoadAll(dirname string, opts ...Option) ([]Stack, error) {
 		return nil, fmt.Errorf("unable to read dir, %v: %w", dirname, err)
 	}
 
+	var paths []string
+	for _, s := range stacks {
+		paths = appendArtifactPaths(paths, filepath.Dir(s.Filename), s.TemplateBody)
+	}
+
+	var filtered []Stack
+	for _, s := range stacks {
+		if isArtifact(s.Filename, paths) {
+			log.Printf("skipping %v.  file is referenced as an artifact\n", s.Filename)
+			continue
+		}
+		filtered = append(filtered, s)
+	}
+
+	stacks, err := sortStacks(link(filtered))
+	if err != nil {
+		return nil, fmt.Errorf("unable to order stacks in dir, %v: %w", dirname, err)
+	}
+
 	return stacks, nil
 }
@@ -46,6 +46,7 @@ func TestLoadAll(t *testing.T) {
 	opts := []Option{
 		//WithDryRun(true),
 		WithPrefix("testing"),
+		WithAllowDelete(true),
 	}
 	manager := New(cloudformation.New(config), opts...)
 
@@ -61,7 +62,10 @@ func TestLoadAll(t *testing.T) {
 			t.Fatalf("got %v; want nil", err)
 		}
 
-		changes := CalculateChanges(summaries, stacks)
+		changes, err := CalculateChanges(summaries, stacks)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
 		fmt.Println()
 		fmt.Println(dir, changes)
 		err = manager.Apply(ctx, changes...)
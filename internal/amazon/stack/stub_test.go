@@ -0,0 +1,197 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/cloudformationiface"
+)
+
+// stubAPI is a cloudformation api that records the operations called, by operation and
+// stack name, and answers them from its fields without calling aws.  Operations it does
+// not override panic.
+type stubAPI struct {
+	cloudformationiface.ClientAPI
+
+	// ChangeSetStatus and ChangeSetReason describe every change set
+	ChangeSetStatus cloudformation.ChangeSetStatus
+	ChangeSetReason string
+	// StackEvents holds the events of each stack by stack name, newest first
+	StackEvents map[string][]cloudformation.StackEvent
+	// StackStatus describes every stack
+	StackStatus cloudformation.StackStatus
+	// WaitErr is returned by every waiter
+	WaitErr error
+
+	mutex        sync.Mutex
+	calls        []string
+	createStack  *cloudformation.CreateStackInput
+	waitDeadline time.Time // deadline of the context passed to the latest waiter
+}
+
+// stubRequest returns a request that, when sent, answers with output without calling aws
+func stubRequest(input, output interface{}) *aws.Request {
+	config := aws.Config{EndpointResolver: aws.ResolveWithEndpointURL("https://cloudformation.local")}
+	return aws.New(config, aws.Metadata{}, aws.Handlers{}, nil, &aws.Operation{}, input, output)
+}
+
+func (s *stubAPI) record(operation string, stackName *string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls = append(s.calls, operation+" "+aws.StringValue(stackName))
+}
+
+// wait records the deadline of the context passed to a waiter
+func (s *stubAPI) wait(ctx context.Context) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.waitDeadline, _ = ctx.Deadline()
+}
+
+// Calls returns the operations called in order
+func (s *stubAPI) Calls() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]string(nil), s.calls...)
+}
+
+func (s *stubAPI) CancelUpdateStackRequest(input *cloudformation.CancelUpdateStackInput) cloudformation.CancelUpdateStackRequest {
+	s.record("CancelUpdateStack", input.StackName)
+	return cloudformation.CancelUpdateStackRequest{
+		Request: stubRequest(input, &cloudformation.CancelUpdateStackOutput{}),
+		Input:   input,
+	}
+}
+
+func (s *stubAPI) CreateStackRequest(input *cloudformation.CreateStackInput) cloudformation.CreateStackRequest {
+	s.record("CreateStack", input.StackName)
+
+	s.mutex.Lock()
+	s.createStack = input
+	s.mutex.Unlock()
+
+	return cloudformation.CreateStackRequest{
+		Request: stubRequest(input, &cloudformation.CreateStackOutput{}),
+		Input:   input,
+	}
+}
+
+func (s *stubAPI) CreateChangeSetRequest(input *cloudformation.CreateChangeSetInput) cloudformation.CreateChangeSetRequest {
+	s.record("CreateChangeSet", input.StackName)
+	return cloudformation.CreateChangeSetRequest{
+		Request: stubRequest(input, &cloudformation.CreateChangeSetOutput{}),
+		Input:   input,
+	}
+}
+
+func (s *stubAPI) DeleteChangeSetRequest(input *cloudformation.DeleteChangeSetInput) cloudformation.DeleteChangeSetRequest {
+	s.record("DeleteChangeSet", input.StackName)
+	return cloudformation.DeleteChangeSetRequest{
+		Request: stubRequest(input, &cloudformation.DeleteChangeSetOutput{}),
+		Input:   input,
+	}
+}
+
+func (s *stubAPI) DeleteStackRequest(input *cloudformation.DeleteStackInput) cloudformation.DeleteStackRequest {
+	s.record("DeleteStack", input.StackName)
+	return cloudformation.DeleteStackRequest{
+		Request: stubRequest(input, &cloudformation.DeleteStackOutput{}),
+		Input:   input,
+	}
+}
+
+func (s *stubAPI) DescribeChangeSetRequest(input *cloudformation.DescribeChangeSetInput) cloudformation.DescribeChangeSetRequest {
+	s.record("DescribeChangeSet", input.StackName)
+	output := cloudformation.DescribeChangeSetOutput{
+		ChangeSetName: input.ChangeSetName,
+		Status:        s.ChangeSetStatus,
+		StatusReason:  aws.String(s.ChangeSetReason),
+	}
+	return cloudformation.DescribeChangeSetRequest{
+		Request: stubRequest(input, &output),
+		Input:   input,
+	}
+}
+
+func (s *stubAPI) DescribeStackEventsRequest(input *cloudformation.DescribeStackEventsInput) cloudformation.DescribeStackEventsRequest {
+	s.record("DescribeStackEvents", input.StackName)
+	return cloudformation.DescribeStackEventsRequest{
+		Request: stubRequest(input, &cloudformation.DescribeStackEventsOutput{StackEvents: s.StackEvents[aws.StringValue(input.StackName)]}),
+		Input:   input,
+	}
+}
+
+func (s *stubAPI) DescribeStacksRequest(input *cloudformation.DescribeStacksInput) cloudformation.DescribeStacksRequest {
+	s.record("DescribeStacks", input.StackName)
+	output := cloudformation.DescribeStacksOutput{
+		Stacks: []cloudformation.Stack{
+			{StackName: input.StackName, StackStatus: s.StackStatus},
+		},
+	}
+	return cloudformation.DescribeStacksRequest{
+		Request: stubRequest(input, &output),
+		Input:   input,
+	}
+}
+
+func (s *stubAPI) ExecuteChangeSetRequest(input *cloudformation.ExecuteChangeSetInput) cloudformation.ExecuteChangeSetRequest {
+	s.record("ExecuteChangeSet", input.StackName)
+	return cloudformation.ExecuteChangeSetRequest{
+		Request: stubRequest(input, &cloudformation.ExecuteChangeSetOutput{}),
+		Input:   input,
+	}
+}
+
+func (s *stubAPI) WaitUntilChangeSetCreateComplete(_ context.Context, input *cloudformation.DescribeChangeSetInput, _ ...aws.WaiterOption) error {
+	s.record("WaitUntilChangeSetCreateComplete", input.StackName)
+	return s.WaitErr
+}
+
+func (s *stubAPI) WaitUntilStackCreateComplete(ctx context.Context, input *cloudformation.DescribeStacksInput, _ ...aws.WaiterOption) error {
+	s.record("WaitUntilStackCreateComplete", input.StackName)
+	s.wait(ctx)
+	return s.WaitErr
+}
+
+func (s *stubAPI) WaitUntilStackDeleteComplete(ctx context.Context, input *cloudformation.DescribeStacksInput, _ ...aws.WaiterOption) error {
+	s.record("WaitUntilStackDeleteComplete", input.StackName)
+	s.wait(ctx)
+	return s.WaitErr
+}
+
+func (s *stubAPI) WaitUntilStackUpdateComplete(ctx context.Context, input *cloudformation.DescribeStacksInput, _ ...aws.WaiterOption) error {
+	s.record("WaitUntilStackUpdateComplete", input.StackName)
+	s.wait(ctx)
+	return s.WaitErr
+}
+
+// Called returns true if the operation was called on any stack
+func (s *stubAPI) Called(operation string) bool {
+	for _, call := range s.Calls() {
+		if strings.HasPrefix(call, operation+" ") {
+			return true
+		}
+	}
+	return false
+}
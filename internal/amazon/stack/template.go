@@ -0,0 +1,307 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	gf "github.com/awslabs/goformation/v4"
+	gfcloudformation "github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/intrinsics"
+)
+
+// Format identifies the syntax of a template
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+)
+
+// templateSuffix is the file suffix LoadAll recognizes as a template in any directory
+const templateSuffix = ".template"
+
+// templateSuffixes holds the file suffixes LoadAll recognizes as templates.  Suffixes
+// other than templateSuffix are only recognized at the top level of the directory.
+var templateSuffixes = []string{templateSuffix, ".yaml", ".yml", ".json"}
+
+// DetectFormat returns the format of the template body.  JSON templates are identified by
+// their leading brace; everything else is treated as YAML.
+func DetectFormat(body string) Format {
+	if strings.HasPrefix(strings.TrimSpace(body), "{") {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+// isTemplate returns true if the filename, less any render suffix, has a recognized
+// template suffix and is neither a sidecar config nor a partial
+func isTemplate(filename string) bool {
+	filename = strings.TrimSuffix(filename, renderSuffix)
+	if isConfig(filename) || isPartial(filename) {
+		return false
+	}
+	for _, suffix := range templateSuffixes {
+		if strings.HasSuffix(filename, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTemplateSuffix returns true if the filename, less any render suffix, ends in
+// templateSuffix
+func hasTemplateSuffix(filename string) bool {
+	return strings.HasSuffix(strings.TrimSuffix(filename, renderSuffix), templateSuffix)
+}
+
+// isTemplateBody returns true if the body declares either of the top level keys that
+// identify a cloudformation template, Resources or AWSTemplateFormatVersion
+func isTemplateBody(body string) bool {
+	content, err := parseTemplate(body)
+	if err != nil {
+		return false
+	}
+	_, resources := content["Resources"]
+	_, version := content["AWSTemplateFormatVersion"]
+	return resources || version
+}
+
+// parseMutex serializes template parsing.  goformation registers its yaml tag handlers
+// in global state on every parse, which is not safe for concurrent use.
+var parseMutex sync.Mutex
+
+// parseGoformation parses the template body into typed goformation resources using the
+// parser appropriate to its format
+func parseGoformation(body string) (*gfcloudformation.Template, error) {
+	parseMutex.Lock()
+	defer parseMutex.Unlock()
+
+	if DetectFormat(body) == FormatJSON {
+		return gf.ParseJSON([]byte(body))
+	}
+	return gf.ParseYAML([]byte(body))
+}
+
+// parseTemplate converts the template body into its generic json form.  Intrinsic
+// functions are left unresolved e.g. !Sub 'x' becomes {"Fn::Sub": "x"}
+func parseTemplate(body string) (map[string]interface{}, error) {
+	var (
+		data    []byte
+		err     error
+		options = &intrinsics.ProcessorOptions{NoProcess: true}
+	)
+	parseMutex.Lock()
+	if DetectFormat(body) == FormatJSON {
+		data, err = intrinsics.ProcessJSON([]byte(body), options)
+	} else {
+		data, err = intrinsics.ProcessYAML([]byte(body), options)
+	}
+	parseMutex.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cloudformation template: %w", err)
+	}
+
+	var content map[string]interface{}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("failed to parse cloudformation template: %w", err)
+	}
+
+	return content, nil
+}
+
+// inspectTemplate returns the export names declared by the template and the export names
+// it imports via Fn::ImportValue.  Names that cannot be resolved locally are skipped.
+func inspectTemplate(stackName, body string, params map[string]string) (exports, imports []string, err error) {
+	content, err := parseTemplate(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vars := map[string]string{
+		"AWS::StackName": stackName,
+	}
+	if parameters, ok := content["Parameters"].(map[string]interface{}); ok {
+		for name, v := range parameters {
+			if p, ok := v.(map[string]interface{}); ok {
+				if def, ok := p["Default"]; ok {
+					vars[name] = fmt.Sprint(def)
+				}
+			}
+			if value, ok := params[name]; ok {
+				vars[name] = value
+			}
+		}
+	}
+
+	if outputs, ok := content["Outputs"].(map[string]interface{}); ok {
+		for _, v := range outputs {
+			output, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			export, ok := output["Export"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if name, ok := resolveString(export["Name"], vars); ok {
+				exports = append(exports, name)
+			}
+		}
+	}
+
+	walk(content, func(m map[string]interface{}) {
+		if v, ok := m["Fn::ImportValue"]; ok {
+			if name, ok := resolveString(v, vars); ok {
+				imports = appendUnique(imports, name)
+			}
+		}
+	})
+
+	sort.Strings(exports)
+	sort.Strings(imports)
+
+	return exports, imports, nil
+}
+
+var reSubVariable = regexp.MustCompile(`\$\{([^!}][^}]*)}`)
+
+// resolveString evaluates the subset of intrinsic functions commonly used to build
+// export names; Ref, Fn::Sub, and Fn::Join
+func resolveString(v interface{}, vars map[string]string) (string, bool) {
+	switch value := v.(type) {
+	case string:
+		return value, true
+	case map[string]interface{}:
+		if len(value) != 1 {
+			return "", false
+		}
+		if ref, ok := value["Ref"].(string); ok {
+			s, ok := vars[ref]
+			return s, ok
+		}
+		if sub, ok := value["Fn::Sub"]; ok {
+			return resolveSub(sub, vars)
+		}
+		if join, ok := value["Fn::Join"].([]interface{}); ok && len(join) == 2 {
+			delim, ok := join[0].(string)
+			if !ok {
+				return "", false
+			}
+			items, ok := join[1].([]interface{})
+			if !ok {
+				return "", false
+			}
+			var parts []string
+			for _, item := range items {
+				s, ok := resolveString(item, vars)
+				if !ok {
+					return "", false
+				}
+				parts = append(parts, s)
+			}
+			return strings.Join(parts, delim), true
+		}
+	}
+	return "", false
+}
+
+func resolveSub(v interface{}, vars map[string]string) (string, bool) {
+	var text string
+	switch value := v.(type) {
+	case string:
+		text = value
+	case []interface{}:
+		if len(value) != 2 {
+			return "", false
+		}
+		s, ok := value[0].(string)
+		if !ok {
+			return "", false
+		}
+		text = s
+
+		locals, ok := value[1].(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		merged := map[string]string{}
+		for k, v := range vars {
+			merged[k] = v
+		}
+		for k, v := range locals {
+			s, ok := resolveString(v, vars)
+			if !ok {
+				return "", false
+			}
+			merged[k] = s
+		}
+		vars = merged
+	default:
+		return "", false
+	}
+
+	resolved := true
+	text = reSubVariable.ReplaceAllStringFunc(text, func(s string) string {
+		name := s[2 : len(s)-1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		resolved = false
+		return s
+	})
+	text = strings.Replace(text, "${!", "${", -1)
+
+	return text, resolved
+}
+
+// resourceType returns the Type of the generic resource definition provided
+func resourceType(v interface{}) string {
+	if resource, ok := v.(map[string]interface{}); ok {
+		if t, ok := resource["Type"].(string); ok {
+			return t
+		}
+	}
+	return ""
+}
+
+// walk invokes fn for every object contained within v
+func walk(v interface{}, fn func(m map[string]interface{})) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		fn(value)
+		for _, item := range value {
+			walk(item, fn)
+		}
+	case []interface{}:
+		for _, item := range value {
+			walk(item, fn)
+		}
+	}
+}
+
+func appendUnique(ss []string, s string) []string {
+	for _, item := range ss {
+		if item == s {
+			return ss
+		}
+	}
+	return append(ss, s)
+}
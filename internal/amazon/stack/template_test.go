@@ -0,0 +1,97 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoadAll_formats(t *testing.T) {
+	stacks, err := LoadAll("testdata/formats", WithPrefix("local-formats"))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	want := []string{"local-formats-network", "local-formats-subnet"}
+	if got := stackNames(stacks); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := stacks[0].Format, FormatJSON; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := stacks[1].Format, FormatYAML; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := stacks[1].DependsOn, []string{"local-formats-network"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	testCases := map[string]struct {
+		Body string
+		Want Format
+	}{
+		"json": {
+			Body: `{"Resources": {}}`,
+			Want: FormatJSON,
+		},
+		"json with whitespace": {
+			Body: "\n  {\"Resources\": {}}",
+			Want: FormatJSON,
+		},
+		"yaml": {
+			Body: "Resources: {}",
+			Want: FormatYAML,
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			if got := DetectFormat(tc.Body); got != tc.Want {
+				t.Fatalf("got %v; want %v", got, tc.Want)
+			}
+		})
+	}
+}
+
+func TestLoadAll_package(t *testing.T) {
+	stacks, err := LoadAll("testdata/package", WithPrefix("local-example"))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	want := []string{"local-example-app"}
+	if got := stackNames(stacks); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func Test_isTemplateBody(t *testing.T) {
+	testCases := map[string]bool{
+		"AWSTemplateFormatVersion: '2010-09-09'":         true,
+		"Resources: {}":                                  true,
+		`{"Resources": {}}`:                              true,
+		"openapi: '3.0.1'":                               false,
+		`{"name": "hello", "main": "index.js"}`:          false,
+		"Description: parameters only\nParameters: {}\n": false,
+	}
+	for body, want := range testCases {
+		if got := isTemplateBody(body); got != want {
+			t.Fatalf("%v: got %v; want %v", body, got, want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/s3iface"
+)
+
+// maxTemplateBodySize is the largest template cloudformation accepts inline via TemplateBody
+const maxTemplateBodySize = 51200
+
+// templateBucket identifies where templates passed by url are stored
+type templateBucket struct {
+	api    s3iface.ClientAPI
+	region string
+	bucket string
+	prefix string
+}
+
+// templateSource returns either the template body or the url of the uploaded template,
+// whichever should be passed to cloudformation for the stack.  Templates are uploaded
+// when they exceed the inline limit or when all templates are configured to be uploaded.
+func (m *Manager) templateSource(ctx context.Context, stack Stack) (body, url *string, err error) {
+	size := len(stack.TemplateBody)
+	if size <= maxTemplateBodySize && !m.options.UploadTemplates {
+		return aws.String(stack.TemplateBody), nil, nil
+	}
+
+	tb := m.options.TemplateBucket
+	if tb == nil || tb.bucket == "" {
+		if size > maxTemplateBodySize {
+			return nil, nil, fmt.Errorf("template for stack, %v, is %v bytes which exceeds the %v byte inline limit and no template bucket was configured",
+				stack.Name,
+				size,
+				maxTemplateBodySize,
+			)
+		}
+		return aws.String(stack.TemplateBody), nil, nil
+	}
+
+	key := templateKey(tb.prefix, stack.TemplateBody)
+	if err := m.uploadTemplate(ctx, tb, key, stack); err != nil {
+		return nil, nil, err
+	}
+
+	return nil, aws.String(templateURL(tb.region, tb.bucket, key)), nil
+}
+
+func (m *Manager) uploadTemplate(ctx context.Context, tb *templateBucket, key string, stack Stack) (err error) {
+	defer func(begin time.Time) {
+		log.Printf("uploaded template for stack, %v -> s3://%v/%v (%v) - %v\n",
+			stack.Name,
+			tb.bucket,
+			key,
+			time.Now().Sub(begin).Round(time.Millisecond),
+			err,
+		)
+	}(time.Now())
+
+	input := s3.PutObjectInput{
+		Body:   strings.NewReader(stack.TemplateBody),
+		Bucket: aws.String(tb.bucket),
+		Key:    aws.String(key),
+	}
+	if _, err := tb.api.PutObjectRequest(&input).Send(ctx); err != nil {
+		return fmt.Errorf("unable to upload template for stack, %v: %w", stack.Name, err)
+	}
+
+	return nil
+}
+
+// templateKey returns the content addressed key of the template body
+func templateKey(prefix, body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return path.Join(prefix, "templates", hex.EncodeToString(sum[:])+".template")
+}
+
+// templateURL returns the url cloudformation reads an uploaded template from
+func templateURL(region, bucket, key string) string {
+	if region == "" {
+		return fmt.Sprintf("https://%v.s3.amazonaws.com/%v", bucket, key)
+	}
+	return fmt.Sprintf("https://%v.s3.%v.amazonaws.com/%v", bucket, region, key)
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestManager_templateSource(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("inline", func(t *testing.T) {
+		manager := New(nil)
+		body, url, err := manager.templateSource(ctx, Stack{Name: "abc", TemplateBody: "Resources: {}"})
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if body == nil || url != nil {
+			t.Fatalf("got body %v, url %v; want body only", body, url)
+		}
+	})
+
+	t.Run("oversized without bucket", func(t *testing.T) {
+		manager := New(nil)
+		large := strings.Repeat("#", maxTemplateBodySize+1)
+		if _, _, err := manager.templateSource(ctx, Stack{Name: "abc", TemplateBody: large}); err == nil {
+			t.Fatalf("got nil; want err")
+		}
+	})
+}
+
+func Test_templateKey(t *testing.T) {
+	a := templateKey("resources/example/local/latest", "a")
+	b := templateKey("resources/example/local/latest", "b")
+	if a == b {
+		t.Fatalf("got %v; want distinct keys", a)
+	}
+	if got, want := a, "resources/example/local/latest/templates/ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb.template"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := templateURL("us-west-2", "bucket", "a/b.template"), "https://bucket.s3.us-west-2.amazonaws.com/a/b.template"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// ErrInvalidParameters is returned when the parameters supplied to a stack do not satisfy
+// the parameter declarations of its template
+var ErrInvalidParameters = errors.New("invalid parameters")
+
+// parameterDeclaration holds the constraints a template declares for a parameter
+type parameterDeclaration struct {
+	Type                  string
+	Default               interface{}
+	AllowedPattern        string
+	AllowedValues         []interface{}
+	ConstraintDescription string
+	MaxLength             interface{}
+	MaxValue              interface{}
+	MinLength             interface{}
+	MinValue              interface{}
+	NoEcho                interface{}
+}
+
+// typePatterns holds the formats of the aws specific parameter types that can be verified
+// locally
+var typePatterns = map[string]*regexp.Regexp{
+	"AWS::EC2::AvailabilityZone::Name":   regexp.MustCompile(`^[a-z]{2}(-gov)?-[a-z]+-\d[a-z]$`),
+	"AWS::EC2::Image::Id":                regexp.MustCompile(`^ami-[0-9a-f]{8,17}$`),
+	"AWS::EC2::Instance::Id":             regexp.MustCompile(`^i-[0-9a-f]{8,17}$`),
+	"AWS::EC2::KeyPair::KeyName":         regexp.MustCompile(`^.+$`),
+	"AWS::EC2::SecurityGroup::GroupName": regexp.MustCompile(`^.+$`),
+	"AWS::EC2::SecurityGroup::Id":        regexp.MustCompile(`^sg-[0-9a-f]{8,17}$`),
+	"AWS::EC2::Subnet::Id":               regexp.MustCompile(`^subnet-[0-9a-f]{8,17}$`),
+	"AWS::EC2::Volume::Id":               regexp.MustCompile(`^vol-[0-9a-f]{8,17}$`),
+	"AWS::EC2::VPC::Id":                  regexp.MustCompile(`^vpc-[0-9a-f]{8,17}$`),
+	"AWS::Route53::HostedZone::Id":       regexp.MustCompile(`^Z[0-9A-Z]+$`),
+}
+
+// Validate verifies, without calling cloudformation, that the parameters supplied to each
+// stack satisfy the parameter declarations of its template.  Every problem found is
+// reported in the error returned.
+func (m *Manager) Validate(ctx context.Context, stacks ...Stack) error {
+	var (
+		problems  []string
+		providers = providedParameters(stacks)
+	)
+	problems = append(problems, outputConflicts(stacks, m.options.Parameters)...)
+	for _, stack := range stacks {
+		found, err := m.validateStack(ctx, stack, providers)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, found...)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %v", ErrInvalidParameters, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// upsertStacks returns the stacks to be inserted or updated by the changes
+func upsertStacks(changes []Change) []Stack {
+	var stacks []Stack
+	for _, change := range changes {
+		if change.Operation != Delete {
+			stacks = append(stacks, change.Stack)
+		}
+	}
+	return stacks
+}
+
+// validateStack returns the parameter problems found for the stack.  Parameters that will
+// be provided by the outputs of other stacks are not checked.
+func (m *Manager) validateStack(ctx context.Context, stack Stack, providers map[string]string) ([]string, error) {
+	declarations, err := parameterDeclarations(stack.TemplateBody)
+	if err != nil {
+		return nil, fmt.Errorf("unable to validate stack, %v: %w", stack.Name, err)
+	}
+
+	params, redact, err := m.resolveParameters(ctx, stack)
+	if err != nil {
+		return nil, fmt.Errorf("unable to validate stack, %v: %w", stack.Name, err)
+	}
+
+	values := map[string]string{}
+	for _, p := range params {
+		values[aws.StringValue(p.ParameterKey)] = aws.StringValue(p.ParameterValue)
+	}
+
+	var problems []string
+	var supplied []string
+	for name := range stack.Parameters {
+		supplied = append(supplied, name)
+	}
+	sort.Strings(supplied)
+
+	for _, name := range supplied {
+		if _, ok := declarations[name]; !ok {
+			problems = append(problems, fmt.Sprintf("stack, %v, supplies parameter, %v, which the template does not declare", stack.Name, name))
+		}
+	}
+
+	var names []string
+	for name := range declarations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		d := declarations[name]
+		value, ok := values[name]
+		if provider, provided := providers[name]; !ok && provided && provider != stack.Name {
+			continue
+		}
+		if !ok {
+			if d.Default == nil {
+				problems = append(problems, fmt.Sprintf("stack, %v, requires parameter, %v", stack.Name, name))
+				continue
+			}
+			value = fmt.Sprint(d.Default)
+		}
+
+		for _, reason := range d.check(value) {
+			if !containsString(redact, name) {
+				reason = fmt.Sprintf("value, %v, %v", value, reason)
+			}
+			problems = append(problems, fmt.Sprintf("stack, %v, parameter, %v: %v", stack.Name, name, reason))
+		}
+	}
+
+	return problems, nil
+}
+
+// parameterDeclarations returns the parameters declared by the template body
+func parameterDeclarations(body string) (map[string]parameterDeclaration, error) {
+	content, err := parseTemplate(body)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(content["Parameters"])
+	if err != nil {
+		return nil, fmt.Errorf("unable to read template parameters: %w", err)
+	}
+
+	declarations := map[string]parameterDeclaration{}
+	if err := json.Unmarshal(data, &declarations); err != nil {
+		return nil, fmt.Errorf("unable to read template parameters: %w", err)
+	}
+
+	return declarations, nil
+}
+
+// check returns the reasons, if any, the value does not satisfy the declaration
+func (d parameterDeclaration) check(value string) []string {
+	itemType, isList := listType(d.Type)
+
+	items := []string{value}
+	if isList {
+		items = splitList(value)
+	}
+
+	var reasons []string
+	for _, item := range items {
+		reasons = append(reasons, d.checkItem(itemType, item)...)
+	}
+
+	if d.ConstraintDescription != "" {
+		for i, reason := range reasons {
+			reasons[i] = reason + " (" + d.ConstraintDescription + ")"
+		}
+	}
+
+	return reasons
+}
+
+func (d parameterDeclaration) checkItem(itemType, value string) []string {
+	var reasons []string
+
+	switch {
+	case itemType == "Number":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			reasons = append(reasons, "is not a Number")
+			break
+		}
+		if min, ok := toFloat(d.MinValue); ok && n < min {
+			reasons = append(reasons, fmt.Sprintf("is less than MinValue, %v", d.MinValue))
+		}
+		if max, ok := toFloat(d.MaxValue); ok && n > max {
+			reasons = append(reasons, fmt.Sprintf("is greater than MaxValue, %v", d.MaxValue))
+		}
+	case typePatterns[itemType] != nil:
+		if !typePatterns[itemType].MatchString(value) {
+			reasons = append(reasons, fmt.Sprintf("is not a valid %v", itemType))
+		}
+	}
+
+	if min, ok := toFloat(d.MinLength); ok && float64(len(value)) < min {
+		reasons = append(reasons, fmt.Sprintf("is shorter than MinLength, %v", d.MinLength))
+	}
+	if max, ok := toFloat(d.MaxLength); ok && float64(len(value)) > max {
+		reasons = append(reasons, fmt.Sprintf("is longer than MaxLength, %v", d.MaxLength))
+	}
+
+	if d.AllowedPattern != "" {
+		re, err := regexp.Compile(`^(?:` + d.AllowedPattern + `)$`)
+		if err != nil {
+			reasons = append(reasons, fmt.Sprintf("cannot be checked against invalid AllowedPattern, %v", d.AllowedPattern))
+		} else if !re.MatchString(value) {
+			reasons = append(reasons, fmt.Sprintf("does not match AllowedPattern, %v", d.AllowedPattern))
+		}
+	}
+
+	if len(d.AllowedValues) > 0 {
+		var allowed []string
+		for _, v := range d.AllowedValues {
+			allowed = append(allowed, fmt.Sprint(v))
+		}
+		if !containsString(allowed, value) {
+			reasons = append(reasons, fmt.Sprintf("is not one of AllowedValues, %v", strings.Join(allowed, ", ")))
+		}
+	}
+
+	return reasons
+}
+
+// listType returns the item type of list parameter types e.g. List<Number> returns
+// Number.  CommaDelimitedList is treated as a list of strings.
+func listType(t string) (string, bool) {
+	switch {
+	case t == "CommaDelimitedList":
+		return "String", true
+	case strings.HasPrefix(t, "List<") && strings.HasSuffix(t, ">"):
+		return t[len("List<") : len(t)-1], true
+	default:
+		return t, false
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		items = append(items, strings.TrimSpace(item))
+	}
+	return items
+}
+
+// toFloat converts a numeric constraint, which may be declared as a number or a string,
+// into a float
+func toFloat(v interface{}) (float64, bool) {
+	switch value := v.(type) {
+	case float64:
+		return value, true
+	case string:
+		f, err := strconv.ParseFloat(value, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestManager_Validate(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/validate.template")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	valid := map[string]string{
+		"Env":      "dev",
+		"Domain":   "example.com",
+		"Password": "correct-horse",
+		"VpcId":    "vpc-0123456789abcdef0",
+		"Subnets":  "subnet-01234567, subnet-89abcdef",
+	}
+
+	testCases := map[string]struct {
+		Parameters      map[string]string
+		StackParameters map[string]string
+		Want            []string
+	}{
+		"valid": {
+			Parameters: valid,
+		},
+		"missing required": {
+			Parameters: without(valid, "VpcId"),
+			Want:       []string{"stack, test, requires parameter, VpcId"},
+		},
+		"unknown stack parameter": {
+			Parameters:      valid,
+			StackParameters: map[string]string{"Bogus": "x"},
+			Want:            []string{"stack, test, supplies parameter, Bogus, which the template does not declare"},
+		},
+		"allowed values": {
+			Parameters: with(valid, "Env", "qa"),
+			Want:       []string{"stack, test, parameter, Env: value, qa, is not one of AllowedValues, local, dev, prod"},
+		},
+		"allowed pattern": {
+			Parameters: with(valid, "Domain", "Example.com"),
+			Want:       []string{"stack, test, parameter, Domain: value, Example.com, does not match AllowedPattern, [a-z0-9.-]+ (must be a lower case domain name)"},
+		},
+		"number": {
+			Parameters: with(valid, "InstanceCount", "many"),
+			Want:       []string{"stack, test, parameter, InstanceCount: value, many, is not a Number"},
+		},
+		"max value": {
+			Parameters: with(valid, "InstanceCount", "11"),
+			Want:       []string{"stack, test, parameter, InstanceCount: value, 11, is greater than MaxValue, 10"},
+		},
+		"min value": {
+			Parameters: with(valid, "InstanceCount", "0"),
+			Want:       []string{"stack, test, parameter, InstanceCount: value, 0, is less than MinValue, 1"},
+		},
+		"no echo": {
+			Parameters: with(valid, "Password", "short"),
+			Want:       []string{"stack, test, parameter, Password: is shorter than MinLength, 8"},
+		},
+		"list of numbers": {
+			Parameters: with(valid, "Ports", "80,http"),
+			Want:       []string{"stack, test, parameter, Ports: value, 80,http, is not a Number"},
+		},
+		"aws type": {
+			Parameters: with(valid, "VpcId", "subnet-01234567"),
+			Want:       []string{"stack, test, parameter, VpcId: value, subnet-01234567, is not a valid AWS::EC2::VPC::Id"},
+		},
+		"list of aws type": {
+			Parameters: with(valid, "Subnets", "subnet-01234567,vpc-01234567"),
+			Want:       []string{"stack, test, parameter, Subnets: value, subnet-01234567,vpc-01234567, is not a valid AWS::EC2::Subnet::Id"},
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			manager := New(nil, WithParameters(tc.Parameters))
+			s := Stack{
+				Name:         "test",
+				TemplateBody: string(data),
+				Parameters:   tc.StackParameters,
+			}
+
+			err := manager.Validate(context.Background(), s)
+			if len(tc.Want) == 0 {
+				if err != nil {
+					t.Fatalf("got %v; want nil", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrInvalidParameters) {
+				t.Fatalf("got %v; want %v", err, ErrInvalidParameters)
+			}
+			got := strings.Split(strings.TrimPrefix(err.Error(), ErrInvalidParameters.Error()+": "), "; ")
+			if !reflect.DeepEqual(got, tc.Want) {
+				t.Fatalf("got %v; want %v", got, tc.Want)
+			}
+		})
+	}
+}
+
+func with(params map[string]string, key, value string) map[string]string {
+	m := mergeParameters(params)
+	m[key] = value
+	return m
+}
+
+func without(params map[string]string, key string) map[string]string {
+	m := mergeParameters(params)
+	delete(m, key)
+	return m
+}
@@ -16,12 +16,17 @@ package command
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws/external"
+	"github.com/fatih/color"
 	"github.com/savaki/fairy/internal/amazon/role"
 	"github.com/savaki/fairy/internal/amazon/stack"
 	"github.com/savaki/fairy/internal/banner"
@@ -29,14 +34,36 @@ import (
 	"github.com/urfave/cli"
 )
 
+const (
+	// exitInterrupted is the exit code of a pipeline stopped by SIGINT or SIGTERM
+	exitInterrupted = 130
+	// exitTimeout is the exit code of a pipeline that exceeded --timeout
+	exitTimeout = 124
+)
+
 var deployOptions struct {
-	Env      string
-	Dir      string
-	S3Prefix string
-	Project  string
-	RoleARN  string
-	Version  string
-	VpcID    string
+	AllowDelete     bool
+	AllowDeleteEnvs cli.StringSlice
+	Concurrency     int
+	Env             string
+	Dir             string
+	EventLog        string
+	ForceDelete     bool
+	OutputsFile     string
+	OutputsFormat   string
+	PrimaryRegion   string
+	Regions         cli.StringSlice
+	S3Prefix        string
+	Project         string
+	Retain          cli.StringSlice
+	RoleARN         string
+	RollbackSkip    cli.StringSlice
+	StackTimeout    time.Duration
+	Timeout         time.Duration
+	TimeoutAction   string
+	UploadTemplates bool
+	Version         string
+	VpcID           string
 }
 
 var Deploy = cli.Command{
@@ -44,58 +71,166 @@ var Deploy = cli.Command{
 	Aliases: []string{"d"},
 	Usage:   "deploy resources to cloud provider",
 	Action:  deployCommand,
-	Flags: []cli.Flag{
-		cli.StringFlag{
-			Name:        "d,dir",
-			Usage:       "dir to resources",
-			EnvVar:      "DIR",
-			Value:       ".",
-			Destination: &deployOptions.Dir,
-		},
-		cli.StringFlag{
-			Name:        "e,env",
-			Usage:       "name of environment",
-			EnvVar:      "ENV",
-			Value:       "local",
-			Destination: &deployOptions.Env,
-		},
-		cli.StringFlag{
-			Name:        "prefix",
-			Usage:       "prefix for s3 resources",
-			EnvVar:      "S3_PREFIX",
-			Value:       "resources",
-			Destination: &deployOptions.S3Prefix,
-		},
-		cli.StringFlag{
-			Name:        "p,project",
-			Usage:       "project name",
-			Required:    true,
-			EnvVar:      "PROJECT,CODEBUILD_INITIATOR",
-			Destination: &deployOptions.Project,
-		},
-		cli.StringFlag{
-			Name:        "r,role",
-			Usage:       "role to assume",
-			EnvVar:      "ROLE",
-			Destination: &deployOptions.RoleARN,
-		},
-		cli.StringFlag{
-			Name:        "version",
-			Usage:       "app version",
-			EnvVar:      "VERSION",
-			Value:       "latest",
-			Destination: &deployOptions.Version,
-		},
-		cli.StringFlag{
-			Name:        "vpc",
-			Usage:       "aws vpc id",
-			EnvVar:      "VPC_ID",
-			Destination: &deployOptions.VpcID,
-		},
+	Flags:   deployFlags,
+}
+
+// deployFlags are shared by the commands that run the deploy pipeline
+var deployFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:        "allow-delete",
+		Usage:       "allow deletes of stacks no longer backed by a template",
+		EnvVar:      "ALLOW_DELETE",
+		Destination: &deployOptions.AllowDelete,
+	},
+	cli.StringSliceFlag{
+		Name:   "allow-delete-env",
+		Usage:  "environment in which deletes are allowed without --allow-delete",
+		EnvVar: "ALLOW_DELETE_ENVS",
+		Value:  &deployOptions.AllowDeleteEnvs,
+	},
+	cli.IntFlag{
+		Name:        "concurrency",
+		Usage:       "max number of independent stacks to deploy concurrently",
+		EnvVar:      "CONCURRENCY",
+		Value:       1,
+		Destination: &deployOptions.Concurrency,
+	},
+	cli.StringFlag{
+		Name:        "d,dir",
+		Usage:       "dir to resources",
+		EnvVar:      "DIR",
+		Value:       ".",
+		Destination: &deployOptions.Dir,
+	},
+	cli.StringFlag{
+		Name:        "e,env",
+		Usage:       "name of environment",
+		EnvVar:      "ENV",
+		Value:       "local",
+		Destination: &deployOptions.Env,
+	},
+	cli.StringFlag{
+		Name:        "event-log",
+		Usage:       "file to append stack events to as json lines",
+		EnvVar:      "EVENT_LOG",
+		Destination: &deployOptions.EventLog,
+	},
+	cli.BoolFlag{
+		Name:        "force-delete",
+		Usage:       "allow deletes of protected stacks and stacks with stateful resources",
+		EnvVar:      "FORCE_DELETE",
+		Destination: &deployOptions.ForceDelete,
+	},
+	cli.StringFlag{
+		Name:        "on-timeout",
+		Usage:       "what becomes of stack creates and updates that time out; cancel or continue",
+		EnvVar:      "ON_TIMEOUT",
+		Value:       string(stack.TimeoutCancel),
+		Destination: &deployOptions.TimeoutAction,
+	},
+	cli.StringFlag{
+		Name:        "outputs-file",
+		Usage:       "file to write the outputs of the project stacks to once deployed",
+		EnvVar:      "OUTPUTS_FILE",
+		Destination: &deployOptions.OutputsFile,
+	},
+	cli.StringFlag{
+		Name:        "outputs-format",
+		Usage:       "format of the outputs file; dotenv, json, or toml.  defaults to the format implied by the file extension",
+		EnvVar:      "OUTPUTS_FORMAT",
+		Destination: &deployOptions.OutputsFormat,
+	},
+	cli.StringFlag{
+		Name:        "prefix",
+		Usage:       "prefix for s3 resources",
+		EnvVar:      "S3_PREFIX",
+		Value:       "resources",
+		Destination: &deployOptions.S3Prefix,
+	},
+	cli.StringFlag{
+		Name:        "p,project",
+		Usage:       "project name",
+		Required:    true,
+		EnvVar:      "PROJECT,CODEBUILD_INITIATOR",
+		Destination: &deployOptions.Project,
+	},
+	cli.StringFlag{
+		Name:        "primary-region",
+		Usage:       "region of --regions to deploy before the others",
+		EnvVar:      "PRIMARY_REGION",
+		Destination: &deployOptions.PrimaryRegion,
+	},
+	cli.StringSliceFlag{
+		Name:   "regions",
+		Usage:  "region to deploy to; the deploy runs once per region.  defaults to the region of the aws config",
+		EnvVar: "REGIONS",
+		Value:  &deployOptions.Regions,
+	},
+	cli.StringFlag{
+		Name:        "r,role",
+		Usage:       "role to assume",
+		EnvVar:      "ROLE",
+		Destination: &deployOptions.RoleARN,
+	},
+	cli.StringSliceFlag{
+		Name:   "retain",
+		Usage:  "stack or template name that must never be deleted",
+		EnvVar: "RETAIN",
+		Value:  &deployOptions.Retain,
+	},
+	cli.StringSliceFlag{
+		Name:  "rollback-skip",
+		Usage: "resource to skip when continuing a failed update rollback, as stack-name:LogicalId",
+		Value: &deployOptions.RollbackSkip,
+	},
+	cli.DurationFlag{
+		Name:        "stack-timeout",
+		Usage:       "max time each stack operation may take unless set by the stack config e.g. 30m",
+		EnvVar:      "STACK_TIMEOUT",
+		Destination: &deployOptions.StackTimeout,
+	},
+	cli.DurationFlag{
+		Name:        "timeout",
+		Usage:       "max time the whole deploy may take e.g. 1h",
+		EnvVar:      "TIMEOUT",
+		Destination: &deployOptions.Timeout,
+	},
+	cli.BoolFlag{
+		Name:        "upload-templates",
+		Usage:       "pass all templates to cloudformation via s3 rather than only those over the inline size limit",
+		EnvVar:      "UPLOAD_TEMPLATES",
+		Destination: &deployOptions.UploadTemplates,
+	},
+	cli.StringFlag{
+		Name:        "version",
+		Usage:       "app version",
+		EnvVar:      "VERSION",
+		Value:       "latest",
+		Destination: &deployOptions.Version,
+	},
+	cli.StringFlag{
+		Name:        "vpc",
+		Usage:       "aws vpc id",
+		EnvVar:      "VPC_ID",
+		Destination: &deployOptions.VpcID,
 	},
 }
 
 func deployCommand(_ *cli.Context) error {
+	var fns = []deploy.Func{
+		deploy.LoadParameters,
+		deploy.Bootstrap,
+		deploy.Upload,
+		deploy.CloudMapNamespaceIfNotExists,
+		deploy.Templates,
+		deploy.WriteOutputs,
+	}
+
+	return runPipeline("deployment fairy", fns...)
+}
+
+// runPipeline builds the deploy config from deployOptions and invokes each func in order
+func runPipeline(name string, fns ...deploy.Func) error {
 	source, err := external.LoadDefaultAWSConfig()
 	if err != nil {
 		return fmt.Errorf("unable to load aws config: %w", err)
@@ -110,20 +245,50 @@ func deployCommand(_ *cli.Context) error {
 		target = v
 	}
 
+	regions, err := orderRegions(deployOptions.Regions, deployOptions.PrimaryRegion)
+	if err != nil {
+		return err
+	}
+
+	timeoutAction := stack.TimeoutAction(deployOptions.TimeoutAction)
+	if timeoutAction != stack.TimeoutCancel && timeoutAction != stack.TimeoutContinue {
+		return fmt.Errorf("invalid on-timeout, %v: want %v or %v", timeoutAction, stack.TimeoutCancel, stack.TimeoutContinue)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	go cancelOnSignal(ctx, cancel)
+
+	if deployOptions.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, deployOptions.Timeout)
+		defer cancel()
+	}
+
 	deployOptions.Project = filepath.Base(deployOptions.Project)
 	if v := os.Getenv("CODEBUILD_INITIATOR"); v == deployOptions.Project {
 		deployOptions.Project = filepath.Base(v)
 		fmt.Printf("found CODEBUILD_INITIATOR.  assign project to %v\n", deployOptions.Project)
 	}
 
-	banner.Println("deployment fairy started")
+	banner.Printf("%v started\n", name)
 	defer func(begin time.Time) {
-		banner.Printf("deployment fairy completed - %v\n", time.Now().Sub(begin).Round(time.Millisecond))
+		banner.Printf("%v completed - %v\n", name, time.Now().Sub(begin).Round(time.Millisecond))
 	}(time.Now())
 
+	resourcesToSkip, err := parseResourcesToSkip(deployOptions.RollbackSkip)
+	if err != nil {
+		return err
+	}
+
+	// plans and events of every stack share the console so concurrent stacks do not interleave
+	console := stack.NewConsole(color.Output, deployOptions.Concurrency > 1)
+	eventHandlers, closeEvents, err := makeEventHandlers(console, deployOptions.EventLog)
+	if err != nil {
+		return err
+	}
+	defer closeEvents()
+
 	config := deploy.Config{
 		Source:  source,
 		Target:  target,
@@ -136,20 +301,203 @@ func deployCommand(_ *cli.Context) error {
 			stack.S3Prefix: filepath.Join(deployOptions.S3Prefix, deployOptions.Project, deployOptions.Env, deployOptions.Version),
 			stack.Version:  deployOptions.Version,
 		},
+		BootstrapExports: map[string]string{},
+
+		Concurrency:     deployOptions.Concurrency,
+		AllowDelete:     deployOptions.AllowDelete || containsString(deployOptions.AllowDeleteEnvs, deployOptions.Env),
+		ForceDelete:     deployOptions.ForceDelete,
+		Retain:          deployOptions.Retain,
+		UploadTemplates: deployOptions.UploadTemplates,
+		ResourcesToSkip: resourcesToSkip,
+		Console:         console,
+		EventHandlers:   eventHandlers,
+		StackTimeout:    deployOptions.StackTimeout,
+		TimeoutAction:   timeoutAction,
+		OutputsFile:     deployOptions.OutputsFile,
+		OutputsFormat:   deployOptions.OutputsFormat,
 	}
 
-	var fns = []deploy.Func{
-		deploy.Bootstrap,
-		deploy.Upload,
-		deploy.CloudMapNamespaceIfNotExists,
-		deploy.Templates,
+	if len(regions) == 0 {
+		return exitError(ctx, name, runFuncs(ctx, config, fns...))
+	}
+
+	var (
+		results []regionResult
+		failed  bool
+	)
+	for _, region := range regions {
+		if failed || ctx.Err() != nil {
+			results = append(results, regionResult{Region: region, Skipped: true})
+			continue
+		}
+
+		banner.Printf("%v in region, %v\n", name, region)
+		begin := time.Now()
+		err := runFuncs(ctx, regionalConfig(config, region, len(regions) > 1), fns...)
+		results = append(results, regionResult{
+			Region:  region,
+			Elapsed: time.Now().Sub(begin).Round(time.Millisecond),
+			Err:     err,
+		})
+		failed = err != nil
 	}
 
+	banner.Println("region summary")
+	for _, result := range results {
+		fmt.Println(result)
+	}
+
+	for _, result := range results {
+		if result.Err != nil {
+			return exitError(ctx, name, fmt.Errorf("region, %v: %w", result.Region, result.Err))
+		}
+	}
+	return nil
+}
+
+// runFuncs invokes each func in order, stopping at the first error
+func runFuncs(ctx context.Context, config deploy.Config, fns ...deploy.Func) error {
 	for _, fn := range fns {
 		if err := fn(ctx, config); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
+
+// exitError assigns a distinct exit code to pipelines stopped by --timeout or by a signal
+func exitError(ctx context.Context, name string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return cli.NewExitError(fmt.Sprintf("%v timed out after %v: %v", name, deployOptions.Timeout, err), exitTimeout)
+	case ctx.Err() != nil:
+		return cli.NewExitError(fmt.Sprintf("%v interrupted: %v", name, err), exitInterrupted)
+	default:
+		return err
+	}
+}
+
+// regionResult records the outcome of the pipeline in a region
+type regionResult struct {
+	Region  string
+	Elapsed time.Duration
+	Err     error
+	Skipped bool // skipped as an earlier region failed
+}
+
+func (r regionResult) String() string {
+	switch {
+	case r.Skipped:
+		return fmt.Sprintf("%v: skipped", r.Region)
+	case r.Err != nil:
+		return fmt.Sprintf("%v: failed (%v) - %v", r.Region, r.Elapsed, r.Err)
+	default:
+		return fmt.Sprintf("%v: ok (%v)", r.Region, r.Elapsed)
+	}
+}
+
+// orderRegions returns the regions to deploy to, without duplicates, with the primary
+// region, if any, first
+func orderRegions(regions []string, primary string) ([]string, error) {
+	var ordered []string
+	if primary != "" {
+		if !containsString(regions, primary) {
+			return nil, fmt.Errorf("invalid primary-region, %v: not one of regions, %v", primary, strings.Join(regions, ", "))
+		}
+		ordered = append(ordered, primary)
+	}
+	for _, region := range regions {
+		if region != "" && !containsString(ordered, region) {
+			ordered = append(ordered, region)
+		}
+	}
+	return ordered, nil
+}
+
+// regionalConfig returns a copy of the config that targets the region.  Parameters and
+// bootstrap exports are copied so each region resolves its own bootstrap bucket.  When
+// deploying to several regions, the outputs file of each region is named for the region.
+func regionalConfig(config deploy.Config, region string, multiple bool) deploy.Config {
+	config.Source = config.Source.Copy()
+	config.Source.Region = region
+	config.Target = config.Target.Copy()
+	config.Target.Region = region
+
+	parameters := map[string]string{}
+	for k, v := range config.Parameters {
+		parameters[k] = v
+	}
+	config.Parameters = parameters
+	config.BootstrapExports = map[string]string{}
+
+	if multiple && config.OutputsFile != "" {
+		ext := filepath.Ext(config.OutputsFile)
+		config.OutputsFile = strings.TrimSuffix(config.OutputsFile, ext) + "." + region + ext
+	}
+
+	return config
+}
+
+// cancelOnSignal cancels the pipeline on SIGINT or SIGTERM so no further stacks are
+// started and stacks in flight are settled.  A second signal exits immediately.
+func cancelOnSignal(ctx context.Context, cancel context.CancelFunc) {
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(ch)
+
+	select {
+	case <-ctx.Done():
+		return
+	case sig := <-ch:
+		banner.Printf("received %v; stopping.  stacks in progress will be cancelled or allowed to finish\n", sig)
+		cancel()
+	}
+
+	sig := <-ch
+	fmt.Fprintf(os.Stderr, "received %v; exiting immediately\n", sig)
+	os.Exit(exitInterrupted)
+}
+
+// makeEventHandlers returns the handlers that write stack events to the console and, if
+// set, append them to the event log as json lines.  The event log is the only json sink
+// so json is never mixed with the console output.  closer releases the event log.
+func makeEventHandlers(console *stack.Console, eventLog string) (handlers []stack.EventHandler, closer func(), err error) {
+	handlers = append(handlers, console.Event)
+
+	if eventLog == "" {
+		return handlers, func() {}, nil
+	}
+
+	f, err := os.OpenFile(eventLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to open event log, %v: %w", eventLog, err)
+	}
+	handlers = append(handlers, stack.JSONEvents(f))
+
+	return handlers, func() { f.Close() }, nil
+}
+
+// parseResourcesToSkip parses values of the form stack-name:LogicalId into logical ids by
+// stack name
+func parseResourcesToSkip(values []string) (map[string][]string, error) {
+	resources := map[string][]string{}
+	for _, v := range values {
+		parts := strings.SplitN(v, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid rollback-skip, %v: want stack-name:LogicalId", v)
+		}
+		resources[parts[0]] = append(resources[parts[0]], parts[1])
+	}
+	return resources, nil
+}
+
+func containsString(ss []string, want string) bool {
+	for _, s := range ss {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
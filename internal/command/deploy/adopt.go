@@ -0,0 +1,79 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/savaki/fairy/internal/amazon/stack"
+	"github.com/savaki/fairy/internal/banner"
+)
+
+const (
+	TagEnv       = "fairy:env"
+	TagProject   = "fairy:project"
+	TagManagedBy = "fairy:managed-by"
+)
+
+// ownerTags returns the tags that identify stacks owned by the project and env
+func ownerTags(config Config) []cloudformation.Tag {
+	return []cloudformation.Tag{
+		{Key: aws.String(TagEnv), Value: aws.String(config.Env)},
+		{Key: aws.String(TagProject), Value: aws.String(config.Project)},
+		{Key: aws.String(TagManagedBy), Value: aws.String("fairy")},
+	}
+}
+
+// Adopt stamps ownership tags onto existing stacks that match the project prefix and are
+// backed by a template in ${config.Dir}/templates.  Stacks created before ownership tags
+// were introduced must be adopted before they can be deployed.
+func Adopt(ctx context.Context, config Config) error {
+	banner.Println("adopting cloudformation stacks ...")
+
+	opts := stackOptions(config)
+
+	dir := filepath.Join(config.Dir, "templates")
+	stacks, err := stack.LoadAll(dir, opts...)
+	if err != nil {
+		return fmt.Errorf("unable to load templates from dir, %v: %w", dir, err)
+	}
+
+	manager := stack.New(cloudformation.New(config.Target), opts...)
+	unowned, err := manager.ListUnowned(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to adopt stacks: %w", err)
+	}
+
+outer:
+	for _, u := range unowned {
+		name := aws.StringValue(u.StackName)
+		for _, s := range stacks {
+			if s.Name == name {
+				if err := manager.Adopt(ctx, u); err != nil {
+					return fmt.Errorf("unable to adopt stacks: %w", err)
+				}
+				continue outer
+			}
+		}
+		log.Printf("skipping stack, %v.  stack is not backed by a template in %v\n", name, dir)
+	}
+
+	return nil
+}
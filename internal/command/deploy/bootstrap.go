@@ -17,6 +17,7 @@ package deploy
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
@@ -25,6 +26,9 @@ import (
 	_ "github.com/savaki/fairy/resources"
 )
 
+// bootstrapExportPrefix prefixes the names of the bootstrap stack exports
+const bootstrapExportPrefix = "fairy-bootstrap-"
+
 // Bootstrap ensures resources required by the deployment fairy exist
 func Bootstrap(ctx context.Context, config Config) error {
 	fileSystem, err := fs.New()
@@ -49,9 +53,16 @@ func Bootstrap(ctx context.Context, config Config) error {
 		return fmt.Errorf("bootstrap failed: %w", err)
 	}
 
+	return LookupBootstrap(ctx, config)
+}
+
+// LookupBootstrap assigns parameters from the exports of an existing bootstrap stack
+// without modifying it
+func LookupBootstrap(ctx context.Context, config Config) error {
+	manager := stack.New(cloudformation.New(config.Target), stack.WithPrefix(config.Env))
 	exports, err := manager.Exports(ctx)
 	if err != nil {
-		return fmt.Errorf("bootstrap failed: %w", err)
+		return fmt.Errorf("bootstrap lookup failed: %w", err)
 	}
 	for _, e := range exports {
 		k, v := aws.StringValue(e.Name), aws.StringValue(e.Value)
@@ -59,6 +70,9 @@ func Bootstrap(ctx context.Context, config Config) error {
 		case "fairy-bootstrap-AssetBucket":
 			config.Parameters[stack.S3Bucket] = v
 		}
+		if name := strings.TrimPrefix(k, bootstrapExportPrefix); name != k && config.BootstrapExports != nil {
+			config.BootstrapExports[name] = v
+		}
 	}
 
 	return nil
@@ -75,6 +75,27 @@ func CloudMapNamespaceIfNotExists(ctx context.Context, config Config) error {
 	}
 }
 
+// LookupCloudMapNamespace assigns the arn of an existing cloudmap namespace, if any, without
+// creating one
+func LookupCloudMapNamespace(ctx context.Context, config Config) error {
+	api := servicediscovery.New(config.Target)
+
+	var ae awserr.Error
+	nss, err := listNamespaces(ctx, api, config.Env)
+	if err != nil && (!errors.As(err, &ae) || ae.Code() != servicediscovery.ErrCodeNamespaceNotFound) {
+		return fmt.Errorf("failed to request cloudmap namespace, %v: %w", config.Env, err)
+	}
+	if len(nss) == 0 {
+		log.Printf("cloudmap namespace, %v, not found.  it will be created on deploy if vpc is set.\n", config.Env)
+		return nil
+	}
+
+	ns := nss[0]
+	log.Printf("using existing cloudmap namespace, %v (%v)\n", aws.StringValue(ns.Name), aws.StringValue(ns.Id))
+	config.Parameters[stack.CloudMapNamespaceARN] = aws.StringValue(ns.Arn)
+	return nil
+}
+
 func listNamespaces(ctx context.Context, api servicediscoveryiface.ClientAPI, envs ...string) ([]servicediscovery.NamespaceSummary, error) {
 	var summaries []servicediscovery.NamespaceSummary
 	var token *string
@@ -16,7 +16,10 @@ package deploy
 
 import (
 	"context"
+	"time"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/savaki/fairy/internal/amazon/stack"
 )
 
 type Config struct {
@@ -27,6 +30,48 @@ type Config struct {
 	Project    string
 	Parameters map[string]string
 	VpcID      string
+
+	// BootstrapExports holds the exports of the bootstrap stack keyed by name less the
+	// fairy-bootstrap- prefix e.g. AssetBucket
+	BootstrapExports map[string]string
+
+	// Concurrency is the maximum number of independent stacks to apply at once
+	Concurrency int
+
+	// AllowDelete permits stacks no longer backed by a template to be deleted
+	AllowDelete bool
+	// ForceDelete permits deletes of protected stacks and stacks with stateful resources
+	ForceDelete bool
+	// Retain holds the names of stacks that must never be deleted
+	Retain []string
+
+	// UploadTemplates passes every template to cloudformation by s3 url rather than only
+	// those exceeding the inline size limit
+	UploadTemplates bool
+
+	// ResourcesToSkip holds, by stack name, resources to skip when continuing a failed
+	// update rollback
+	ResourcesToSkip map[string][]string
+
+	// OutputsFile, if set, receives the outputs of the project stacks once deployed
+	OutputsFile string
+	// OutputsFormat holds the format of OutputsFile; dotenv, json, or toml.  Defaults to
+	// the format implied by the file extension.
+	OutputsFormat string
+
+	// StackTimeout bounds the operations of stacks that set no timeout of their own
+	StackTimeout time.Duration
+	// TimeoutAction determines whether creates and updates that exceed their timeout are cancelled
+	TimeoutAction stack.TimeoutAction
+
+	// Console receives the plans of each stack.  Sharing one console with the event
+	// handlers keeps the output of concurrent stacks from interleaving.
+	Console *stack.Console
+	// EventHandlers receive the events of each stack as it changes
+	EventHandlers []stack.EventHandler
+
+	// OnPlan, if set, receives the change set plan of each stack before it is executed
+	OnPlan func(plan stack.Plan)
 }
 
 type Func func(ctx context.Context, config Config) error
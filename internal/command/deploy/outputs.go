@@ -0,0 +1,129 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/savaki/fairy/internal/amazon/stack"
+	"github.com/savaki/fairy/internal/banner"
+)
+
+// Output file formats supported by WriteOutputs
+const (
+	OutputsDotenv = "dotenv"
+	OutputsJSON   = "json"
+	OutputsTOML   = "toml"
+)
+
+// WriteOutputs writes the outputs of the project stacks to ${config.OutputsFile}, if set,
+// so later build steps need not describe the stacks themselves
+func WriteOutputs(ctx context.Context, config Config) error {
+	if config.OutputsFile == "" {
+		return nil
+	}
+
+	banner.Println("writing cloudformation outputs ...")
+
+	format := config.OutputsFormat
+	if format == "" {
+		format = outputsFormat(config.OutputsFile)
+	}
+
+	stacks, err := loadStacks(config)
+	if err != nil {
+		return err
+	}
+
+	manager := stack.New(cloudformation.New(config.Target), stackOptions(config)...)
+	outputs, err := manager.ReadOutputs(ctx, stacks...)
+	if err != nil {
+		return fmt.Errorf("unable to write outputs: %w", err)
+	}
+
+	data, err := formatOutputs(format, outputs)
+	if err != nil {
+		return fmt.Errorf("unable to write outputs: %w", err)
+	}
+
+	if err := ioutil.WriteFile(config.OutputsFile, data, 0644); err != nil {
+		return fmt.Errorf("unable to write outputs to file, %v: %w", config.OutputsFile, err)
+	}
+
+	banner.Printf("wrote %v outputs to %v\n", len(outputs), config.OutputsFile)
+	return nil
+}
+
+// outputsFormat returns the format implied by the extension of the filename; json unless
+// the file is named .env, *.env, or *.toml
+func outputsFormat(filename string) string {
+	switch filepath.Ext(filename) {
+	case ".env":
+		return OutputsDotenv
+	case ".toml":
+		return OutputsTOML
+	default:
+		return OutputsJSON
+	}
+}
+
+// formatOutputs encodes the outputs in the format requested
+func formatOutputs(format string, outputs map[string]string) ([]byte, error) {
+	switch format {
+	case OutputsDotenv:
+		var keys []string
+		for k := range outputs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf := bytes.NewBuffer(nil)
+		for _, k := range keys {
+			v := outputs[k]
+			if strings.ContainsAny(v, " \t\r\n\"'#$\\") {
+				v = strconv.Quote(v)
+			}
+			fmt.Fprintf(buf, "%v=%v\n", k, v)
+		}
+		return buf.Bytes(), nil
+
+	case OutputsJSON:
+		data, err := json.MarshalIndent(outputs, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode outputs as json: %w", err)
+		}
+		return append(data, '\n'), nil
+
+	case OutputsTOML:
+		buf := bytes.NewBuffer(nil)
+		if err := toml.NewEncoder(buf).Encode(outputs); err != nil {
+			return nil, fmt.Errorf("unable to encode outputs as toml: %w", err)
+		}
+		return buf.Bytes(), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported outputs format, %v: want %v, %v, or %v", format, OutputsDotenv, OutputsJSON, OutputsTOML)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+func Test_formatOutputs(t *testing.T) {
+	outputs := map[string]string{
+		"NetworkVpcId": "vpc-0123456789abcdef0",
+		"ApiUrl":       "https://example.com/api",
+		"Motd":         "hello world",
+	}
+
+	testCases := map[string]struct {
+		Format string
+		Want   string
+	}{
+		"dotenv": {
+			Format: OutputsDotenv,
+			Want:   "ApiUrl=https://example.com/api\nMotd=\"hello world\"\nNetworkVpcId=vpc-0123456789abcdef0\n",
+		},
+		"json": {
+			Format: OutputsJSON,
+			Want:   "{\n  \"ApiUrl\": \"https://example.com/api\",\n  \"Motd\": \"hello world\",\n  \"NetworkVpcId\": \"vpc-0123456789abcdef0\"\n}\n",
+		},
+		"toml": {
+			Format: OutputsTOML,
+			Want:   "ApiUrl = \"https://example.com/api\"\nMotd = \"hello world\"\nNetworkVpcId = \"vpc-0123456789abcdef0\"\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := formatOutputs(tc.Format, outputs)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			if string(got) != tc.Want {
+				t.Fatalf("got %v; want %v", string(got), tc.Want)
+			}
+		})
+	}
+
+	if _, err := formatOutputs("xml", outputs); err == nil {
+		t.Fatalf("got nil; want err")
+	}
+}
+
+func Test_formatOutputs_promote(t *testing.T) {
+	data, err := formatOutputs(OutputsTOML, map[string]string{"IMAGE": "123456789012.dkr.ecr.us-west-2.amazonaws.com/app:v1"})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	var content struct {
+		Image string `toml:"IMAGE"`
+	}
+	if _, err := toml.Decode(string(data), &content); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := content.Image, "123456789012.dkr.ecr.us-west-2.amazonaws.com/app:v1"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func Test_outputsFormat(t *testing.T) {
+	testCases := map[string]string{
+		"outputs.json": OutputsJSON,
+		"outputs":      OutputsJSON,
+		".env":         OutputsDotenv,
+		"build/ci.env": OutputsDotenv,
+		"outputs.toml": OutputsTOML,
+	}
+
+	for filename, want := range testCases {
+		t.Run(filename, func(t *testing.T) {
+			if got := outputsFormat(filename); got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
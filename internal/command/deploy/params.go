@@ -0,0 +1,142 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sanathkr/go-yaml"
+	"github.com/savaki/fairy/internal/amazon/stack"
+	"github.com/savaki/fairy/internal/banner"
+)
+
+// reservedParameters holds the parameters assigned by fairy itself.  Parameter files
+// may not override them.
+var reservedParameters = []string{
+	stack.CloudMapNamespaceARN,
+	stack.Env,
+	stack.S3Bucket,
+	stack.S3Prefix,
+	stack.Version,
+}
+
+// LoadParameters merges ${config.Dir}/params/common.yaml and ${config.Dir}/params/${env}.yaml,
+// if they exist, into config.Parameters.  Values from the env file replace values from
+// common.yaml.  The file each value came from is reported but not the value itself, which
+// the manager logs per stack with NoEcho values redacted.
+func LoadParameters(_ context.Context, config Config) error {
+	banner.Println("loading parameters ...")
+
+	dir := filepath.Join(config.Dir, "params")
+	params, sources, err := loadParameters(dir, config.Env)
+	if err != nil {
+		return fmt.Errorf("unable to load parameters from dir, %v: %w", dir, err)
+	}
+
+	var keys []string
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		config.Parameters[k] = params[k]
+		log.Printf("parameter %v (%v)\n", k, sources[k])
+	}
+
+	return nil
+}
+
+// loadParameters returns the layered parameters from common.yaml and ${env}.yaml within dir
+// along with the filename each parameter was read from
+func loadParameters(dir, env string) (params, sources map[string]string, err error) {
+	params = map[string]string{}
+	sources = map[string]string{}
+	for _, name := range []string{"common", env} {
+		filename, values, err := readParameterFile(dir, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		for k, v := range values {
+			if containsString(reservedParameters, k) {
+				return nil, nil, fmt.Errorf("parameter file, %v, may not assign reserved parameter, %v", filename, k)
+			}
+			params[k] = v
+			sources[k] = filename
+		}
+	}
+	return params, sources, nil
+}
+
+// readParameterFile reads ${dir}/${name}.yaml or ${dir}/${name}.yml.  No error is
+// returned if neither exists.
+func readParameterFile(dir, name string) (string, map[string]string, error) {
+	for _, ext := range []string{".yaml", ".yml"} {
+		filename := filepath.Join(dir, name+ext)
+		data, err := ioutil.ReadFile(filename)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("unable to read parameter file, %v: %w", filename, err)
+		}
+
+		var content map[string]interface{}
+		if err := yaml.Unmarshal(data, &content); err != nil {
+			return "", nil, fmt.Errorf("unable to parse parameter file, %v: %w", filename, err)
+		}
+
+		values := map[string]string{}
+		for k, v := range content {
+			s, err := formatParameter(v)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid parameter, %v, in file, %v: %w", k, filename, err)
+			}
+			values[k] = s
+		}
+		return filename, values, nil
+	}
+	return "", nil, nil
+}
+
+// formatParameter converts a yaml value into a cloudformation parameter value.  Lists
+// are joined with commas for use with CommaDelimitedList parameters.
+func formatParameter(v interface{}) (string, error) {
+	switch value := v.(type) {
+	case nil:
+		return "", nil
+	case []interface{}:
+		var ss []string
+		for _, item := range value {
+			s, err := formatParameter(item)
+			if err != nil {
+				return "", err
+			}
+			ss = append(ss, s)
+		}
+		return strings.Join(ss, ","), nil
+	case map[interface{}]interface{}:
+		return "", fmt.Errorf("nested values are not supported")
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
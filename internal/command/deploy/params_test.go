@@ -0,0 +1,72 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_loadParameters(t *testing.T) {
+	t.Run("layered", func(t *testing.T) {
+		params, sources, err := loadParameters("testdata/params", "prod")
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		want := map[string]string{
+			"Domain":        "example.com",
+			"EnableFeature": "true",
+			"InstanceType":  "m5.large",
+			"MinSize":       "3",
+			"Subnets":       "subnet-a,subnet-b",
+		}
+		if !reflect.DeepEqual(params, want) {
+			t.Fatalf("got %v; want %v", params, want)
+		}
+		if got, want := sources["Domain"], "testdata/params/common.yaml"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := sources["InstanceType"], "testdata/params/prod.yaml"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("common only", func(t *testing.T) {
+		params, _, err := loadParameters("testdata/params", "dev")
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := params["InstanceType"], "t3.micro"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("missing dir", func(t *testing.T) {
+		params, _, err := loadParameters("testdata/missing", "dev")
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := len(params), 0; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("reserved", func(t *testing.T) {
+		if _, _, err := loadParameters("testdata/params", "invalid"); err == nil {
+			t.Fatalf("got nil; want err")
+		}
+	})
+}
@@ -17,38 +17,141 @@ package deploy
 import (
 	"context"
 	"fmt"
-	"github.com/savaki/fairy/internal/banner"
+	"log"
 	"path/filepath"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/savaki/fairy/internal/amazon/param"
 	"github.com/savaki/fairy/internal/amazon/stack"
+	"github.com/savaki/fairy/internal/banner"
 )
 
 // Templates upserts all templates from ${config.Dir}/templates if it exists
 func Templates(ctx context.Context, config Config) error {
 	banner.Println("deploying cloudformation templates ...")
 
-	opts := []stack.Option{
-		stack.WithPrefix(config.Env + "-" + config.Project),
-		stack.WithNameFormatter(func(s string) string { return "-" + s }),
-		stack.WithParameters(config.Parameters),
+	manager, changes, err := loadChanges(ctx, config)
+	if err != nil {
+		return err
 	}
 
+	if err := manager.Apply(ctx, changes...); err != nil {
+		if failures := manager.Failures(); len(failures) > 0 {
+			banner.Println("failed cloudformation stacks ...")
+			fmt.Print(stack.SummarizeFailures(failures...))
+		}
+		if interrupted := manager.Interrupted(); len(interrupted) > 0 {
+			banner.Println("outstanding cloudformation stacks ...")
+			for _, i := range interrupted {
+				fmt.Println(i)
+			}
+		}
+		return fmt.Errorf("unable to apply templates: %w", err)
+	}
+
+	return nil
+}
+
+// PlanTemplates reports the changes Templates would make without applying them
+func PlanTemplates(ctx context.Context, config Config) error {
+	banner.Println("planning cloudformation templates ...")
+
+	manager, changes, err := loadChanges(ctx, config)
+	if err != nil {
+		return err
+	}
+
+	plans, err := manager.Preview(ctx, changes...)
+	if err != nil {
+		return fmt.Errorf("unable to plan templates: %w", err)
+	}
+
+	banner.Printf("plan: %v\n", stack.Summarize(plans...))
+
+	return nil
+}
+
+// loadChanges loads the templates from ${config.Dir}/templates and calculates the
+// changes required to bring the deployed stacks in line with them
+func loadChanges(ctx context.Context, config Config) (*stack.Manager, []stack.Change, error) {
+	opts := stackOptions(config)
+
 	dir := filepath.Join(config.Dir, "templates")
 	stacks, err := stack.LoadAll(dir, opts...)
 	if err != nil {
-		return fmt.Errorf("unable to load templates from dir, %v: %w", dir, err)
+		return nil, nil, fmt.Errorf("unable to load templates from dir, %v: %w", dir, err)
 	}
 
 	manager := stack.New(cloudformation.New(config.Target), opts...)
+	if err := checkUnowned(ctx, manager, stacks); err != nil {
+		return nil, nil, err
+	}
+
+	stacks, err = manager.Package(ctx, stacks...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to package templates from dir, %v: %w", dir, err)
+	}
+
 	summaries, err := manager.List(ctx)
 	if err != nil {
-		return fmt.Errorf("unable to load templates from dir, %v: %w", dir, err)
+		return nil, nil, fmt.Errorf("unable to load templates from dir, %v: %w", dir, err)
 	}
 
-	changes := stack.CalculateChanges(summaries, stacks)
-	if err := manager.Apply(ctx, changes...); err != nil {
-		return fmt.Errorf("unable to apply templates: %w", err)
+	changes, err := stack.CalculateChanges(summaries, stacks)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to load templates from dir, %v: %w", dir, err)
+	}
+
+	return manager, changes, nil
+}
+
+// stackOptions returns the options used to load and manage the project stacks
+func stackOptions(config Config) []stack.Option {
+	opts := []stack.Option{
+		stack.WithPrefix(config.Env + "-" + config.Project),
+		stack.WithNameFormatter(func(s string) string { return "-" + s }),
+		stack.WithParameters(config.Parameters),
+		stack.WithParameterResolver(param.New(config.Target).Resolve),
+		stack.WithProject(config.Project),
+		stack.WithBootstrapExports(config.BootstrapExports),
+		stack.WithPlanHandler(config.OnPlan),
+		stack.WithConcurrency(config.Concurrency),
+		stack.WithAllowDelete(config.AllowDelete),
+		stack.WithForceDelete(config.ForceDelete),
+		stack.WithRetain(config.Retain...),
+		stack.WithOwnerTags(ownerTags(config)...),
+		stack.WithTemplateBucket(s3.New(config.Target), config.Target.Region, config.Parameters[stack.S3Bucket], config.Parameters[stack.S3Prefix]),
+		stack.WithUploadTemplates(config.UploadTemplates),
+		stack.WithTimeout(config.StackTimeout, config.TimeoutAction),
+		stack.WithConsole(config.Console),
+	}
+	for _, fn := range config.EventHandlers {
+		opts = append(opts, stack.WithEventHandler(fn))
+	}
+	for stackName, logicalIDs := range config.ResourcesToSkip {
+		opts = append(opts, stack.WithResourcesToSkip(stackName, logicalIDs...))
+	}
+	return opts
+}
+
+// checkUnowned fails if a template would collide with an existing stack that predates
+// ownership tags.  Such stacks must first be adopted.
+func checkUnowned(ctx context.Context, manager *stack.Manager, stacks []stack.Stack) error {
+	unowned, err := manager.ListUnowned(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to check stack ownership: %w", err)
+	}
+
+	for _, u := range unowned {
+		name := aws.StringValue(u.StackName)
+		for _, s := range stacks {
+			if s.Name == name {
+				return fmt.Errorf("stack, %v, exists, but is not tagged as owned by this project.  run fairy adopt to take ownership", name)
+			}
+		}
+		log.Printf("ignoring stack, %v.  stack matches project prefix, but is not tagged as owned by this project\n", name)
 	}
 
 	return nil
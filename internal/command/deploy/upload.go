@@ -21,28 +21,11 @@ func Upload(ctx context.Context, config Config) error {
 	api := s3.New(config.Target)
 
 	once := &sync.Once{}
-	dir := filepath.Join(config.Dir, "resources")
-	dir = strings.TrimRight(dir, "/") + "/"
-	fn := func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
-		}
-
+	fn := func(path, bucket, key string) (err error) {
 		once.Do(func() {
 			banner.Println("uploading resources ...")
 		})
 
-		rel := path
-		if strings.HasPrefix(path, dir) {
-			rel = rel[len(dir):]
-		}
-
-		bucket := config.Parameters[stack.S3Bucket]
-		key := filepath.Join(config.Parameters[stack.S3Prefix], rel)
-
 		defer func(begin time.Time) {
 			log.Printf("uploaded %v -> s3://%v/%v (%v) - %v", path, bucket, key, time.Now().Sub(begin).Round(time.Millisecond), err)
 		}(time.Now())
@@ -64,7 +47,48 @@ func Upload(ctx context.Context, config Config) error {
 
 		return nil
 	}
-	if err := filepath.Walk(dir, fn); err != nil {
+
+	return walkResources(config, fn)
+}
+
+// ListUploads prints the resources Upload would copy to s3 without uploading them
+func ListUploads(_ context.Context, config Config) error {
+	once := &sync.Once{}
+	fn := func(path, bucket, key string) error {
+		once.Do(func() {
+			banner.Println("listing resources ...")
+		})
+		log.Printf("would upload %v -> s3://%v/%v", path, bucket, key)
+		return nil
+	}
+
+	return walkResources(config, fn)
+}
+
+// walkResources invokes fn for each file within ${config.Dir}/resources along with the
+// s3 bucket and key the file is uploaded to
+func walkResources(config Config, fn func(path, bucket, key string) error) error {
+	dir := filepath.Join(config.Dir, "resources")
+	dir = strings.TrimRight(dir, "/") + "/"
+	walkFn := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel := path
+		if strings.HasPrefix(path, dir) {
+			rel = rel[len(dir):]
+		}
+
+		bucket := config.Parameters[stack.S3Bucket]
+		key := filepath.Join(config.Parameters[stack.S3Prefix], rel)
+
+		return fn(path, bucket, key)
+	}
+	if err := filepath.Walk(dir, walkFn); err != nil {
 		if os.IsNotExist(err) {
 			return nil
 		}
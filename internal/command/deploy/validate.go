@@ -0,0 +1,91 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/savaki/fairy/internal/amazon/stack"
+	"github.com/savaki/fairy/internal/banner"
+)
+
+// ValidateTemplates checks each template from ${config.Dir}/templates for problems that
+// can be found without calling aws
+func ValidateTemplates(_ context.Context, config Config) error {
+	banner.Println("validating cloudformation templates ...")
+
+	stacks, err := loadStacks(config)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	for _, s := range stacks {
+		problems, err := stack.Lint(s)
+		if err != nil {
+			return fmt.Errorf("unable to validate stack, %v: %w", s.Name, err)
+		}
+		for _, problem := range problems {
+			fmt.Printf("%v: %v\n", s.Name, problem)
+		}
+		count += len(problems)
+	}
+
+	if count > 0 {
+		return fmt.Errorf("found %v problems in %v templates", count, len(stacks))
+	}
+
+	banner.Printf("validated %v templates\n", len(stacks))
+	return nil
+}
+
+// ValidateTemplatesRemote asks cloudformation to validate each template from
+// ${config.Dir}/templates
+func ValidateTemplatesRemote(ctx context.Context, config Config) error {
+	banner.Println("validating cloudformation templates with cloudformation ...")
+
+	stacks, err := loadStacks(config)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	manager := stack.New(cloudformation.New(config.Target), stackOptions(config)...)
+	for _, s := range stacks {
+		if err := manager.ValidateTemplate(ctx, s); err != nil {
+			fmt.Printf("%v: %v\n", s.Name, err)
+			count++
+		}
+	}
+
+	if count > 0 {
+		return fmt.Errorf("cloudformation rejected %v of %v templates", count, len(stacks))
+	}
+
+	return nil
+}
+
+// loadStacks loads the templates from ${config.Dir}/templates
+func loadStacks(config Config) ([]stack.Stack, error) {
+	dir := filepath.Join(config.Dir, "templates")
+	stacks, err := stack.LoadAll(dir, stackOptions(config)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load templates from dir, %v: %w", dir, err)
+	}
+	return stacks, nil
+}
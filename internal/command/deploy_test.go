@@ -0,0 +1,140 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/savaki/fairy/internal/amazon/stack"
+	"github.com/savaki/fairy/internal/command/deploy"
+)
+
+func Test_parseResourcesToSkip(t *testing.T) {
+	got, err := parseResourcesToSkip([]string{"local-example--table:Table", "local-example--table:Queue", "local-example--api:Service"})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	want := map[string][]string{
+		"local-example--table": {"Table", "Queue"},
+		"local-example--api":   {"Service"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	if _, err := parseResourcesToSkip([]string{"Table"}); err == nil {
+		t.Fatalf("got nil; want err")
+	}
+}
+
+func Test_makeEventHandlers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "events")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	defer os.RemoveAll(dir)
+
+	console := stack.NewConsole(ioutil.Discard, false)
+	eventLog := filepath.Join(dir, "events.jsonl")
+	handlers, closer, err := makeEventHandlers(console, eventLog)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	defer closer()
+
+	if got, want := len(handlers), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	handlers, _, err = makeEventHandlers(console, "")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(handlers), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	if _, _, err := makeEventHandlers(console, filepath.Join(dir, "missing", "events.jsonl")); err == nil {
+		t.Fatalf("got nil; want err")
+	}
+}
+
+func Test_orderRegions(t *testing.T) {
+	testCases := map[string]struct {
+		Regions []string
+		Primary string
+		Want    []string
+	}{
+		"none": {},
+		"as given": {
+			Regions: []string{"us-west-2", "us-east-1", "us-west-2"},
+			Want:    []string{"us-west-2", "us-east-1"},
+		},
+		"primary first": {
+			Regions: []string{"us-west-2", "eu-west-1", "us-east-1"},
+			Primary: "us-east-1",
+			Want:    []string{"us-east-1", "us-west-2", "eu-west-1"},
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			got, err := orderRegions(tc.Regions, tc.Primary)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			if !reflect.DeepEqual(got, tc.Want) {
+				t.Fatalf("got %v; want %v", got, tc.Want)
+			}
+		})
+	}
+
+	if _, err := orderRegions([]string{"us-west-2"}, "us-east-1"); err == nil {
+		t.Fatalf("got nil; want err")
+	}
+}
+
+func Test_regionalConfig(t *testing.T) {
+	config := deploy.Config{
+		Parameters:       map[string]string{stack.Env: "local", stack.S3Bucket: "fairy-123456789012-us-west-2"},
+		BootstrapExports: map[string]string{"AssetBucket": "fairy-123456789012-us-west-2"},
+		OutputsFile:      "build/outputs.json",
+	}
+
+	got := regionalConfig(config, "us-east-1", true)
+	if got.Target.Region != "us-east-1" || got.Source.Region != "us-east-1" {
+		t.Fatalf("got %v; want us-east-1", got.Target.Region)
+	}
+	if got, want := got.OutputsFile, "build/outputs.us-east-1.json"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if len(got.BootstrapExports) != 0 {
+		t.Fatalf("got %v; want empty bootstrap exports", got.BootstrapExports)
+	}
+
+	got.Parameters[stack.S3Bucket] = "fairy-123456789012-us-east-1"
+	if got, want := config.Parameters[stack.S3Bucket], "fairy-123456789012-us-west-2"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	if got, want := regionalConfig(config, "us-east-1", false).OutputsFile, "build/outputs.json"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
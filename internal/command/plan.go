@@ -0,0 +1,39 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"github.com/savaki/fairy/internal/command/deploy"
+	"github.com/urfave/cli"
+)
+
+var Plan = cli.Command{
+	Name:   "plan",
+	Usage:  "preview the changes deploy would make without applying them",
+	Action: planCommand,
+	Flags:  deployFlags,
+}
+
+func planCommand(_ *cli.Context) error {
+	var fns = []deploy.Func{
+		deploy.LoadParameters,
+		deploy.LookupBootstrap,
+		deploy.ListUploads,
+		deploy.LookupCloudMapNamespace,
+		deploy.PlanTemplates,
+	}
+
+	return runPipeline("deployment fairy plan", fns...)
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 Matt Ho
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"github.com/savaki/fairy/internal/command/deploy"
+	"github.com/urfave/cli"
+)
+
+var validateOptions struct {
+	Remote bool
+}
+
+var Validate = cli.Command{
+	Name:   "validate",
+	Usage:  "check templates for problems without changing any stacks",
+	Action: validateCommand,
+	Flags: append([]cli.Flag{
+		cli.BoolFlag{
+			Name:        "remote",
+			Usage:       "also validate templates with cloudformation; requires aws credentials",
+			EnvVar:      "VALIDATE_REMOTE",
+			Destination: &validateOptions.Remote,
+		},
+	}, deployFlags...),
+}
+
+func validateCommand(_ *cli.Context) error {
+	if !validateOptions.Remote {
+		return runPipeline("deployment fairy validate", deploy.LoadParameters, deploy.ValidateTemplates)
+	}
+
+	var fns = []deploy.Func{
+		deploy.LoadParameters,
+		deploy.LookupBootstrap,
+		deploy.ValidateTemplates,
+		deploy.ValidateTemplatesRemote,
+	}
+
+	return runPipeline("deployment fairy validate", fns...)
+}
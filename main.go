@@ -28,8 +28,11 @@ func main() {
 	app.Usage = "the deployment fairy makes your deployments come true"
 	app.UsageText = "fairy [command] [options]"
 	app.Commands = []cli.Command{
+		command.Adopt,
 		command.Deploy,
 		command.Docker,
+		command.Plan,
+		command.Validate,
 		command.Version,
 	}
 	app.HideVersion = true